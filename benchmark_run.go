@@ -0,0 +1,349 @@
+package main
+
+import (
+	"github.com/RedisGraph/redisgraph-go"
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/time/rate"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// benchmarkWorkload holds the queries and load settings of a single benchmark run ( or of a single scenario phase )
+type benchmarkWorkload struct {
+	name            string
+	queries         []string
+	queryIsReadOnly []bool
+	cmdRates        []float64
+	cdf             []float32
+	clients         uint64
+	numberRequests  uint64
+	// when set, the clients issue commands up until the duration is reached instead of issuing numberRequests
+	duration       time.Duration
+	rps            int64
+	pipeline       uint64
+	warmupRequests uint64
+	warmupTime     time.Duration
+	// evaluated against the final results of the run
+	slos []sloCheck
+}
+
+// benchmarkRunner holds the settings shared by all the runs of a single benchmark invocation
+type benchmarkRunner struct {
+	graphKey        string
+	network         string
+	connectionStr   string
+	password        string
+	tlsCaCertFile   string
+	resolver        *sentinelResolver
+	failoverTimeout time.Duration
+	// endpoint 0 is the primary. read-only queries are routed to the replicas if there are any.
+	endpoints              []string
+	continueOnError        bool
+	debug                  int
+	randomIntMin           int64
+	randLimit              int64
+	dataReplacementEnabled bool
+	replacementArr         []map[string]string
+	cliUpdateTick          time.Duration
+	// live sinks of the stats of each reporting period and of the results of each run
+	exporters         []benchmarkExporter
+	runName           string
+	gitSHA            string
+	redisgraphVersion int64
+	// when set, the per tick histograms are written to it
+	intervalOutput intervalHistogramsOutput
+	// sample the server stats on every reporting period
+	serverStats bool
+	// collect GRAPH.SLOWLOG, optionally fetching it periodically along the run
+	slowlog           bool
+	slowlogPollPeriod time.Duration
+	slowlogMaxEntries int
+	// when closed the run is interrupted as on Ctrl-c ( e.g. by the coordinator of a distributed run )
+	stop <-chan struct{}
+}
+
+// connect opens the connections of each client.
+// read-only queries use the same connection unless there are replicas to route them to
+func (b *benchmarkRunner) connect(clients uint64) (rgs []redisgraph.Graph, roRgs []redisgraph.Graph, roEndpointsPos []int, conns []redis.Conn) {
+	rgs = make([]redisgraph.Graph, clients)
+	roRgs = make([]redisgraph.Graph, clients)
+	roEndpointsPos = make([]int, clients)
+	conns = make([]redis.Conn, 0, clients)
+	replicas := b.endpoints[1:]
+	for client_id := 0; uint64(client_id) < clients; client_id++ {
+		var conn redis.Conn
+		if b.resolver != nil {
+			rgs[client_id], conn = getSentinelConn(b.graphKey, b.resolver, -1, b.failoverTimeout, b.password, b.tlsCaCertFile)
+		} else {
+			rgs[client_id], conn = getStandaloneConn(b.graphKey, b.network, b.connectionStr, b.password, b.tlsCaCertFile)
+		}
+		conns = append(conns, conn)
+		roRgs[client_id] = rgs[client_id]
+		if len(replicas) > 0 {
+			roEndpointsPos[client_id] = 1 + client_id%len(replicas)
+			if b.resolver != nil {
+				// the replicas are re-resolved via sentinel on failover
+				roRgs[client_id], conn = getSentinelConn(b.graphKey, b.resolver, client_id%len(replicas), b.failoverTimeout, b.password, b.tlsCaCertFile)
+			} else {
+				roRgs[client_id], conn = getStandaloneConn(b.graphKey, "tcp", b.endpoints[roEndpointsPos[client_id]], b.password, b.tlsCaCertFile)
+			}
+			conns = append(conns, conn)
+		}
+	}
+	return
+}
+
+// run executes the workload and returns its results.
+// completed is false if the run was interrupted via Ctrl-c
+func (b *benchmarkRunner) run(w benchmarkWorkload) (testResult *TestResult, completed bool) {
+	testResult = NewTestResult("", uint(w.clients), w.numberRequests, uint64(w.rps), w.name)
+	testResult.Pipeline = w.pipeline
+	testResult.BenchmarkConfiguredDurationMillis = w.duration.Milliseconds()
+	loop := w.duration > 0
+
+	var requestRate = Inf
+	var requestBurst = 1
+	useRateLimiter := false
+	if w.rps != 0 {
+		requestRate = rate.Limit(w.rps)
+		// each client reserves a full pipeline at once
+		requestBurst = int(w.clients * w.pipeline)
+		useRateLimiter = true
+	}
+
+	var rateLimiter = rate.NewLimiter(requestRate, requestBurst)
+	samplesPerClient := w.numberRequests / w.clients
+	samplesPerClientRemainder := w.numberRequests % w.clients
+
+	// a WaitGroup for the goroutines to tell us they've stopped
+	wg := sync.WaitGroup{}
+	if !loop {
+		log.Printf("Total clients: %d. Commands per client: %d Total commands: %d\n", w.clients, samplesPerClient, w.numberRequests)
+		if samplesPerClientRemainder != 0 {
+			log.Printf("Last client will issue: %d commands.\n", samplesPerClientRemainder+samplesPerClient)
+		}
+	} else {
+		log.Printf("Total clients: %d. Running for %v\n", w.clients, w.duration)
+	}
+	if w.pipeline > 1 {
+		log.Printf("Each client pipelines %d commands at a time.\n", w.pipeline)
+	}
+
+	// the metrics endpoint ( if enabled ) reads the globals concurrently
+	instantHistogramsResetMutex.Lock()
+	createRequiredGlobalStructs(len(w.queries), len(b.endpoints))
+	metricsQueries = w.queries
+	instantHistogramsResetMutex.Unlock()
+
+	// a WaitGroup for the goroutines to tell us they've stopped
+	dataPointProcessingWg := sync.WaitGroup{}
+	graphDatapointsChann := make(chan GraphQueryDatapoint, w.clients)
+	warmupDatapointsChann := make(chan GraphQueryDatapoint, w.clients)
+
+	// listen for C-c
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	defer signal.Stop(c)
+
+	c1 := make(chan os.Signal, 1)
+	signal.Notify(c1, os.Interrupt)
+	defer signal.Stop(c1)
+
+	runFinished := make(chan struct{})
+	defer close(runFinished)
+	if b.stop != nil {
+		go func() {
+			select {
+			case <-b.stop:
+				for _, sigChan := range []chan os.Signal{c, c1} {
+					select {
+					case sigChan <- os.Interrupt:
+					default:
+					}
+				}
+			case <-runFinished:
+			}
+		}()
+	}
+	// closed once the run is over, so that the clients stop issuing commands when it was interrupted
+	stopClients := make(chan struct{})
+
+	dataPointProcessingWg.Add(1)
+	go processGraphDatapointsChannel(graphDatapointsChann, warmupDatapointsChann, c1, &dataPointProcessingWg, &instantHistogramsResetMutex)
+
+	rgs, roRgs, roEndpointsPos, conns := b.connect(w.clients)
+
+	// the warmup runs on the same connections, but its datapoints are not accounted on the benchmark results
+	var warmupStartTime, warmupEndTime time.Time
+	if w.warmupRequests > 0 || w.warmupTime > 0 {
+		var warmupDeadline time.Time
+		warmupLoop := w.warmupRequests == 0
+		if w.warmupTime > 0 {
+			warmupDeadline = time.Now().Add(w.warmupTime)
+		}
+		log.Printf("Running warmup. Warmup requests: %d. Warmup time: %v\n", w.warmupRequests, w.warmupTime)
+		warmupStartTime = time.Now()
+		warmupWg := sync.WaitGroup{}
+		warmupPerClient := w.warmupRequests / w.clients
+		// each client starts on its own replacement terms, as on the measured run. On a time based warmup
+		// the clients are spread as on the measured run
+		warmupStride := warmupPerClient
+		if warmupStride == 0 {
+			warmupStride = samplesPerClient
+		}
+		for client_id := 0; uint64(client_id) < w.clients; client_id++ {
+			warmupWg.Add(1)
+			clientWarmupCmds := warmupPerClient
+			if uint64(client_id) == (w.clients - uint64(1)) {
+				clientWarmupCmds += w.warmupRequests % w.clients
+			}
+			cmdStartPos := uint64(client_id) * warmupStride
+			go ingestionRoutine(&rgs[client_id], &roRgs[client_id], 0, roEndpointsPos[client_id], b.continueOnError, w.queries, w.queryIsReadOnly, w.cdf, b.randomIntMin, b.randLimit, clientWarmupCmds, warmupLoop, warmupDeadline, stopClients, w.pipeline, b.debug, &warmupWg, useRateLimiter, rateLimiter, warmupDatapointsChann, b.dataReplacementEnabled, b.replacementArr, cmdStartPos)
+		}
+		warmupWg.Wait()
+		warmupEndTime = time.Now()
+		log.Printf("Warmup finished. Issued %d commands (%d errors) in %.3f seconds. Starting to measure.\n", atomic.LoadUint64(&warmupCommands), atomic.LoadUint64(&warmupErrors), warmupEndTime.Sub(warmupStartTime).Seconds())
+	}
+
+	var sampler *serverStatsSampler = nil
+	if b.serverStats {
+		sampler = newServerStatsSampler(func() (redis.Conn, error) { return b.dialStatsConn(0) }, b.graphKey, b.cliUpdateTick)
+		sampler.start()
+	}
+	var slowlog *slowlogCollector = nil
+	if b.slowlog {
+		slowlog = newSlowlogCollector(b.endpoints, b.dialStatsConn, b.graphKey, b.slowlogPollPeriod, b.slowlogMaxEntries)
+		slowlog.start()
+	}
+
+	tick := time.NewTicker(b.cliUpdateTick)
+	defer tick.Stop()
+	// Total commands to be issue per client. Equal for all clients with exception of the last one ( see comment bellow )
+	clientTotalCmds := samplesPerClient
+	startTime := time.Now()
+	var deadline time.Time
+	if loop {
+		deadline = startTime.Add(w.duration)
+	}
+	for client_id := 0; uint64(client_id) < w.clients; client_id++ {
+		wg.Add(1)
+		// Given the total commands might not be divisible by the #clients
+		// the last client will send the remainder commands to match the desired request count.
+		// It's OK to alter clientTotalCmds given this is the last time we use it's value
+		if uint64(client_id) == (w.clients - uint64(1)) {
+			clientTotalCmds = samplesPerClientRemainder + samplesPerClient
+		}
+		cmdStartPos := uint64(client_id) * samplesPerClient
+		go ingestionRoutine(&rgs[client_id], &roRgs[client_id], 0, roEndpointsPos[client_id], b.continueOnError, w.queries, w.queryIsReadOnly, w.cdf, b.randomIntMin, b.randLimit, clientTotalCmds, loop, deadline, stopClients, w.pipeline, b.debug, &wg, useRateLimiter, rateLimiter, graphDatapointsChann, b.dataReplacementEnabled, b.replacementArr, cmdStartPos)
+	}
+	clientsDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(clientsDone)
+	}()
+
+	// enter the update loop
+	var series *tickSeries = nil
+	if len(b.exporters) > 0 {
+		series = newTickSeries(b.exporters, b.runName, b.gitSHA, b.redisgraphVersion, w)
+	}
+	testResult.ClientRunTimeStats = map[int64]interface{}{}
+	completed = updateCLI(startTime, tick, c, clientsDone, w.numberRequests, loop, w.queries, testResult.ClientRunTimeStats, series, b.intervalOutput)
+
+	endTime := time.Now()
+	duration := time.Since(startTime)
+	if sampler != nil {
+		sampler.finish()
+		testResult.ServerRunTimeStats = sampler.getRunTimeStats()
+		testResult.ServerStats = getServerStatsDeltas(sampler.samples)
+	}
+	if slowlog != nil {
+		testResult.Slowlog = slowlog.finish()
+	}
+
+	// on Ctrl-c the clients might still be issuing commands. Their in flight commands are awaited before
+	// closing the connections, and their datapoints discarded given the datapoints processor already stopped
+	close(stopClients)
+	if !completed {
+		go func() {
+			for range graphDatapointsChann {
+			}
+		}()
+	}
+	<-clientsDone
+	close(graphDatapointsChann)
+
+	// benchmarked ended, close the connections
+	for _, standaloneConn := range conns {
+		standaloneConn.Close()
+	}
+
+	//wait for all stats to be processed
+	dataPointProcessingWg.Wait()
+
+	testResult.FillDurationInfo(startTime, endTime, duration)
+	if !warmupStartTime.IsZero() {
+		testResult.FillWarmupInfo(warmupStartTime, warmupEndTime, w.queries)
+	}
+	testResult.BenchmarkFullyRun = completed && (loop || totalCommands == w.numberRequests)
+	testResult.IssuedCommands = totalCommands
+	overallGraphInternalLatencies, internalLatencyMap := GetOverallLatencies(w.queries, serverSide_PerQuery_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+	overallClientLatencies, clientLatencyMap := GetOverallLatencies(w.queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	relativeLatencyDiff, absoluteLatencyDiff := GenerateInternalExternalRatioLatencies(internalLatencyMap, clientLatencyMap)
+	testResult.OverallClientLatencies = overallClientLatencies
+	testResult.OverallGraphInternalLatencies = overallGraphInternalLatencies
+	testResult.AbsoluteInternalExternalLatencyDiff = absoluteLatencyDiff
+	testResult.RelativeInternalExternalLatencyDiff = relativeLatencyDiff
+	testResult.OverallQueryRates = GetOverallRatesMap(duration, w.queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	testResult.Endpoints = b.endpoints
+	testResult.OverallEndpointRates = GetOverallRatesMap(duration, b.endpoints, clientSide_PerEndpoint_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	testResult.OverallEndpointClientLatencies, _ = GetOverallLatencies(b.endpoints, clientSide_PerEndpoint_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	testResult.OverallEndpointGraphInternalLatencies, _ = GetOverallLatencies(b.endpoints, serverSide_PerEndpoint_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+	testResult.Totals = GetTotalsMap(w.queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies, errorsPerQuery, totalNodesCreatedPerQuery, totalNodesDeletedPerQuery, totalLabelsAddedPerQuery, totalPropertiesSetPerQuery, totalRelationshipsCreatedPerQuery, totalRelationshipsDeletedPerQuery)
+	testResult.EncodedClientHistograms = GetEncodedHistogramsMap(w.queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	testResult.EncodedGraphInternalHistograms = GetEncodedHistogramsMap(w.queries, serverSide_PerQuery_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+	testResult.SLOs = evaluateSLOs(w.slos, w.queries, duration)
+
+	// final merge of pending stats
+	printFinalSummary(w.queries, b.endpoints, w.cmdRates, totalCommands, duration, testResult.SLOs, testResult.ServerStats, testResult.Slowlog)
+	if series != nil {
+		series.addResult(testResult, endTime)
+	}
+	return
+}
+
+// getPrimaryConn opens a new connection to the primary, via sentinel if enabled
+func (b *benchmarkRunner) getPrimaryConn() (graph redisgraph.Graph, conn redis.Conn) {
+	if b.resolver != nil {
+		return getSentinelConn(b.graphKey, b.resolver, -1, b.failoverTimeout, b.password, b.tlsCaCertFile)
+	}
+	return getStandaloneConn(b.graphKey, b.network, b.connectionStr, b.password, b.tlsCaCertFile)
+}
+
+// dialStatsConn opens a plain connection with short timeouts to the given endpoint, 0 being the current primary.
+// Contrary to getPrimaryConn it never waits for a failover, so that the stats sampling and slowlog collection can't block the run
+func (b *benchmarkRunner) dialStatsConn(endpointPos int) (redis.Conn, error) {
+	network, addr := b.network, b.connectionStr
+	if endpointPos > 0 {
+		network, addr = "tcp", b.endpoints[endpointPos]
+	} else if b.resolver != nil {
+		network = "tcp"
+		addr, _ = b.resolver.Master()
+	}
+	return dialStandalone(network, addr, b.password, b.tlsCaCertFile,
+		redis.DialConnectTimeout(statsConnTimeout),
+		redis.DialReadTimeout(statsConnTimeout),
+		redis.DialWriteTimeout(statsConnTimeout),
+	)
+}
+
+// deleteGraph issues GRAPH.DELETE on the benchmark graph key
+func (b *benchmarkRunner) deleteGraph() error {
+	graph, conn := b.getPrimaryConn()
+	defer conn.Close()
+	return graph.Delete()
+}
@@ -3,8 +3,8 @@ package main
 import (
 	"fmt"
 	"github.com/HdrHistogram/hdrhistogram-go"
-	redistimeseries "github.com/RedisTimeSeries/redistimeseries-go"
-	"github.com/olekukonko/tablewriter"
+	"io"
+	"log"
 	"os"
 	"sync/atomic"
 	"time"
@@ -21,29 +21,57 @@ func (i *arrayStringParameters) Set(value string) error {
 	return nil
 }
 
-func printFinalSummary(queries []string, queryRates []float64, totalMessages uint64, duration time.Duration) {
-	writer := os.Stdout
+func printFinalSummary(queries []string, endpoints []string, queryRates []float64, totalMessages uint64, duration time.Duration, sloResults []SLOResult, serverStats []serverStatsDelta, slowlog []slowlogEntry) {
+	renderFinalSummary(os.Stdout, queries, endpoints, totalMessages, duration, sloResults, serverStats, slowlog)
+}
+
+func renderFinalSummary(writer io.Writer, queries []string, endpoints []string, totalMessages uint64, duration time.Duration, sloResults []SLOResult, serverStats []serverStatsDelta, slowlog []slowlogEntry) {
 	messageRate := float64(totalMessages) / float64(duration.Seconds())
 
-	fmt.Printf("\n")
-	fmt.Printf("################# RUNTIME STATS #################\n")
-	fmt.Printf("Total Duration %.3f Seconds\n", duration.Seconds())
-	fmt.Printf("Total Commands issued %d\n", totalCommands)
-	fmt.Printf("Total Errors %d ( %3.3f %%)\n", totalErrors, float64(totalErrors/totalCommands*100.0))
-	fmt.Printf("Throughput summary: %.0f requests per second\n", messageRate)
-	renderGraphResultSetTable(queries, writer, "## Overall RedisGraph resultset stats table\n")
-	renderGraphInternalExecutionTimeTable(queries, writer, "## Overall RedisGraph Internal Execution Time summary table\n", serverSide_PerQuery_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
-	renderTable(queries, writer, "## Overall Client Latency summary table\n", true, true, errorsPerQuery, duration, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	if outputFormat == outputFormatCsv {
+		// all the metrics of a query on a single row, starting on the first line, followed by the
+		// per endpoint, server stats, slowest queries and SLO sections ( if any )
+		if err := renderSummaryCsv(writer, queries, duration); err != nil {
+			log.Printf("Unable to output the csv summary. Error: %v\n", err)
+		}
+	} else {
+		fmt.Fprintf(writer, "\n")
+		errorPercent := 0.0
+		if totalCommands > 0 {
+			errorPercent = float64(totalErrors) / float64(totalCommands) * 100.0
+		}
+		renderRuntimeStats(writer, []string{
+			fmt.Sprintf("Total Duration %.3f Seconds", duration.Seconds()),
+			fmt.Sprintf("Total Commands issued %d", totalCommands),
+			fmt.Sprintf("Total Errors %d ( %3.3f %%)", totalErrors, errorPercent),
+			fmt.Sprintf("Throughput summary: %.0f requests per second", messageRate),
+		})
+		renderGraphResultSetTable(queries, writer, "## Overall RedisGraph resultset stats table\n")
+		renderGraphInternalExecutionTimeTable(queries, writer, "## Overall RedisGraph Internal Execution Time summary table\n", serverSide_PerQuery_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+		renderTable(queries, writer, "## Overall Client Latency summary table\n", true, true, errorsPerQuery, duration, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	}
+	// only worth detailing per endpoint when the read-only queries were routed to replicas
+	if len(endpoints) > 1 {
+		renderGraphInternalExecutionTimeTable(endpoints, writer, "## Per endpoint RedisGraph Internal Execution Time summary table\n", serverSide_PerEndpoint_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+		renderTable(endpoints, writer, "## Per endpoint Client Latency summary table\n", true, true, errorsPerEndpoint, duration, clientSide_PerEndpoint_OverallLatencies, clientSide_AllQueries_OverallLatencies)
+	}
+	if len(serverStats) > 0 {
+		renderServerStatsTable(serverStats, writer, "## Server stats summary table\n")
+	}
+	if len(slowlog) > 0 {
+		renderSlowlogTable(slowlog, writer, "## Slowest queries table ( GRAPH.SLOWLOG )\n")
+	}
+	if len(sloResults) > 0 {
+		renderSLOTable(sloResults, writer, "## SLO summary table\n")
+	}
 }
 
-func renderTable(queries []string, writer *os.File, tableTitle string, includeCalls bool, includeErrors bool, errorSlice []uint64, duration time.Duration, detailedHistogram []*hdrhistogram.Histogram, overallHistogram *hdrhistogram.Histogram) {
-	fmt.Fprintf(writer, tableTitle)
+func renderTable(queries []string, writer io.Writer, tableTitle string, includeCalls bool, includeErrors bool, errorSlice []uint64, duration time.Duration, detailedHistogram []*hdrhistogram.Histogram, overallHistogram *hdrhistogram.Histogram) {
 	data := make([][]string, len(queries)+1)
 	for i := 0; i < len(queries); i++ {
 		insertTableLine(queries[i], data, i, includeCalls, includeErrors, errorSlice, duration, detailedHistogram[i])
 	}
 	insertTableLine("Total", data, len(queries), includeCalls, includeErrors, errorSlice, duration, overallHistogram)
-	table := tablewriter.NewWriter(writer)
 	initialHeader := []string{"Query"}
 	if includeCalls {
 		initialHeader = append(initialHeader, "Ops/sec")
@@ -52,52 +80,26 @@ func renderTable(queries []string, writer *os.File, tableTitle string, includeCa
 	if includeErrors {
 		initialHeader = append(initialHeader, "Total Errors")
 	}
-	initialHeader = append(initialHeader, "Avg. latency(ms)", "p50 latency(ms)", "p95 latency(ms)", "p99 latency(ms)")
-	table.SetHeader(initialHeader)
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("|")
-	table.AppendBulk(data)
-	table.Render()
+	initialHeader = append(initialHeader, getLatencyHeader("")...)
+	renderSummaryTable(writer, tableTitle, initialHeader, data)
 }
-func renderGraphInternalExecutionTimeTable(queries []string, writer *os.File, tableTitle string, detailedHistogram []*hdrhistogram.Histogram, overallHistogram *hdrhistogram.Histogram) {
-	fmt.Fprintf(writer, tableTitle)
-	initialHeader := []string{"Query", " Internal Avg. latency(ms)", "Internal p50 latency(ms)", "Internal p95 latency(ms)", "Internal p99 latency(ms)"}
+func renderGraphInternalExecutionTimeTable(queries []string, writer io.Writer, tableTitle string, detailedHistogram []*hdrhistogram.Histogram, overallHistogram *hdrhistogram.Histogram) {
+	initialHeader := append([]string{"Query"}, getLatencyHeader("Internal ")...)
 	data := make([][]string, len(queries)+1)
 	i := 0
 	for i = 0; i < len(queries); i++ {
-		data[i] = make([]string, 5)
-		data[i][0] = queries[i]
-		data[i][1] = fmt.Sprintf("%.3f", float64(detailedHistogram[i].Mean()/1000.0))
-		data[i][2] = fmt.Sprintf("%.3f", float64(detailedHistogram[i].ValueAtQuantile(50.0))/1000.0)
-		data[i][3] = fmt.Sprintf("%.3f", float64(detailedHistogram[i].ValueAtQuantile(95.0))/1000.0)
-		data[i][4] = fmt.Sprintf("%.3f", float64(detailedHistogram[i].ValueAtQuantile(99.0))/1000.0)
+		data[i] = append([]string{queries[i]}, getLatencyColumns(detailedHistogram[i])...)
 	}
-	data[i] = make([]string, 5)
-	data[i][0] = "Total"
-	data[i][1] = fmt.Sprintf("%.3f", float64(overallHistogram.Mean()/1000.0))
-	data[i][2] = fmt.Sprintf("%.3f", float64(overallHistogram.ValueAtQuantile(50.0))/1000.0)
-	data[i][3] = fmt.Sprintf("%.3f", float64(overallHistogram.ValueAtQuantile(95.0))/1000.0)
-	data[i][4] = fmt.Sprintf("%.3f", float64(overallHistogram.ValueAtQuantile(99.0))/1000.0)
-	table := tablewriter.NewWriter(writer)
-	table.SetHeader(initialHeader)
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("|")
-	table.AppendBulk(data) // Add Bulk Data
-	table.Render()
+	data[i] = append([]string{"Total"}, getLatencyColumns(overallHistogram)...)
+	renderSummaryTable(writer, tableTitle, initialHeader, data)
 }
 
 func insertTableLine(queryName string, data [][]string, i int, includeCalls, includeErrors bool, errorsSlice []uint64, duration time.Duration, histogram *hdrhistogram.Histogram) {
-	data[i] = make([]string, 5)
-	latencyPadding := 0
-	data[i][0] = queryName
+	data[i] = []string{queryName}
 	if includeCalls {
 		totalCmds := histogram.TotalCount()
 		cmdRate := float64(totalCmds) / float64(duration.Seconds())
-		data[i][1] = fmt.Sprintf("%.f", cmdRate)
-		data[i][2] = fmt.Sprintf("%d", histogram.TotalCount())
-		data[i] = append(data[i], "", "")
-		latencyPadding += 2
-
+		data[i] = append(data[i], fmt.Sprintf("%.f", cmdRate), fmt.Sprintf("%d", histogram.TotalCount()))
 	}
 	if includeErrors {
 		var errorV uint64
@@ -107,18 +109,12 @@ func insertTableLine(queryName string, data [][]string, i int, includeCalls, inc
 		} else {
 			errorV = errorsSlice[i]
 		}
-		data[i][1+latencyPadding] = fmt.Sprintf("%d", errorV)
-		data[i] = append(data[i], "")
-		latencyPadding++
+		data[i] = append(data[i], fmt.Sprintf("%d", errorV))
 	}
-	data[i][1+latencyPadding] = fmt.Sprintf("%.3f", float64(histogram.Mean()/1000.0))
-	data[i][2+latencyPadding] = fmt.Sprintf("%.3f", float64(histogram.ValueAtQuantile(50.0))/1000.0)
-	data[i][3+latencyPadding] = fmt.Sprintf("%.3f", float64(histogram.ValueAtQuantile(95.0))/1000.0)
-	data[i][4+latencyPadding] = fmt.Sprintf("%.3f", float64(histogram.ValueAtQuantile(99.0))/1000.0)
+	data[i] = append(data[i], getLatencyColumns(histogram)...)
 }
 
-func renderGraphResultSetTable(queries []string, writer *os.File, tableTitle string) {
-	fmt.Fprintf(writer, tableTitle)
+func renderGraphResultSetTable(queries []string, writer io.Writer, tableTitle string) {
 	initialHeader := []string{"Query", "Nodes created", "Nodes deleted", "Labels added", "Properties set", " Relationships created", " Relationships deleted"}
 	data := make([][]string, len(queries)+1)
 	i := 0
@@ -140,15 +136,10 @@ func renderGraphResultSetTable(queries []string, writer *os.File, tableTitle str
 	data[i][4] = fmt.Sprintf("%d", totalPropertiesSet)
 	data[i][5] = fmt.Sprintf("%d", totalRelationshipsCreated)
 	data[i][6] = fmt.Sprintf("%d", totalRelationshipsDeleted)
-	table := tablewriter.NewWriter(writer)
-	table.SetHeader(initialHeader)
-	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
-	table.SetCenterSeparator("|")
-	table.AppendBulk(data) // Add Bulk Data
-	table.Render()
+	renderSummaryTable(writer, tableTitle, initialHeader, data)
 }
 
-func updateCLI(startTime time.Time, tick *time.Ticker, c chan os.Signal, message_limit uint64, loop bool, client *redistimeseries.Client, suffix string) bool {
+func updateCLI(startTime time.Time, tick *time.Ticker, c chan os.Signal, done chan struct{}, message_limit uint64, loop bool, queries []string, runTimeStats map[int64]interface{}, series *tickSeries, intervalOutput intervalHistogramsOutput) bool {
 
 	start := startTime
 	prevTime := startTime
@@ -156,67 +147,67 @@ func updateCLI(startTime time.Time, tick *time.Ticker, c chan os.Signal, message
 	var currentCmds uint64
 	var currentErrs uint64
 	messageRateTs := []float64{}
-	fmt.Printf("%26s %7s %25s %25s %7s %25s %25s %26s\n", "Test time", " ", "Total Commands", "Total Errors", "", "Command Rate", "Client p50 with RTT(ms)", "Graph Internal Time p50 (ms)")
+	progress := getProgressWriter()
+	fmt.Fprintf(progress, "%26s %7s %25s %25s %7s %25s %25s %26s\n", "Test time", " ", "Total Commands", "Total Errors", "", "Command Rate", "Client p50 with RTT(ms)", "Graph Internal Time p50 (ms)")
 	for {
+		// done is closed as soon as all clients have finished issuing commands
+		finished := false
 		select {
+		case <-done:
+			finished = true
 		case <-tick.C:
-			{
-				now := time.Now()
-				took := now.Sub(prevTime)
-				currentCmds = atomic.LoadUint64(&totalCommands)
-				currentErrs = atomic.LoadUint64(&totalErrors)
-				messageRate := calculateRateMetrics(int64(currentCmds), int64(prevMessageCount), took)
-				completionPercentStr := "[----%]"
-				if !loop {
-					completionPercent := float64(currentCmds) / float64(message_limit) * 100.0
-					completionPercentStr = fmt.Sprintf("[%3.1f%%]", completionPercent)
-				}
-				errorPercent := float64(currentErrs) / float64(currentCmds) * 100.0
-
-				instantHistogramsResetMutex.Lock()
-				p50 := float64(clientSide_AllQueries_OverallLatencies.ValueAtQuantile(50.0)) / 1000.0
-				p50RunTimeGraph := float64(serverSide_AllQueries_GraphInternalTime_OverallLatencies.ValueAtQuantile(50.0)) / 1000.0
-				instantP50 := float64(clientSide_AllQueries_InstantLatencies.ValueAtQuantile(50.0)) / 1000.0
-				instantP50RunTimeGraph := float64(serverSide_AllQueries_GraphInternalTime_InstantLatencies.ValueAtQuantile(50.0)) / 1000.0
-				instantHistogramsResetMutex.Unlock()
-				if currentCmds != 0 {
-					messageRateTs = append(messageRateTs, messageRate)
-				}
-				prevMessageCount = currentCmds
-				prevTime = now
-				if client != nil {
-					opts := redistimeseries.DefaultCreateOptions
-					for _, percentile := range []float64{0, 50.0, 95, 99, 99.9, 100.0} {
-						overallIncludingRTT := float64(clientSide_AllQueries_OverallLatencies.ValueAtQuantile(percentile)) / 1000.0
-						overallRunTimeGraph := float64(serverSide_AllQueries_GraphInternalTime_OverallLatencies.ValueAtQuantile(percentile)) / 1000.0
-						instantIncludingRTT := float64(clientSide_AllQueries_InstantLatencies.ValueAtQuantile(percentile)) / 1000.0
-						instantRunTimeGraph := float64(serverSide_AllQueries_GraphInternalTime_InstantLatencies.ValueAtQuantile(percentile)) / 1000.0
-						opts.Labels = map[string]string{"metric": "overallIncludingRTT"}
-						client.AddWithOptions(fmt.Sprintf("%s:overallIncludingRTT:p%.3f", suffix, percentile), now.UTC().Unix()*1000, overallIncludingRTT, opts)
-						opts.Labels = map[string]string{"metric": "overallRunTimeGraph"}
-						client.AddWithOptions(fmt.Sprintf("%s:overallRunTimeGraph:p%.3f", suffix, percentile), now.UTC().Unix()*1000, overallRunTimeGraph, opts)
-						opts.Labels = map[string]string{"metric": "instantIncludingRTT"}
-						client.AddWithOptions(fmt.Sprintf("%s:instantIncludingRTT:p%.3f", suffix, percentile), now.UTC().Unix()*1000, instantIncludingRTT, opts)
-						opts.Labels = map[string]string{"metric": "instantRunTimeGraph"}
-						client.AddWithOptions(fmt.Sprintf("%s:instantRunTimeGraph:p%.3f", suffix, percentile), now.UTC().Unix()*1000, instantRunTimeGraph, opts)
-					}
-					opts.Labels = map[string]string{"metric": "messageRate"}
-					client.AddWithOptions(fmt.Sprintf("%s:messageRate", suffix), now.UTC().Unix()*1000, messageRate, opts)
-				}
+		case <-c:
+			fmt.Fprintln(progress, "\nReceived Ctrl-c - shutting down cli updater go-routine")
+			return false
+		}
+		now := time.Now()
+		took := now.Sub(prevTime)
+		currentCmds = atomic.LoadUint64(&totalCommands)
+		currentErrs = atomic.LoadUint64(&totalErrors)
+		messageRate := calculateRateMetrics(int64(currentCmds), int64(prevMessageCount), took)
+		completionPercentStr := "[----%]"
+		if !loop {
+			completionPercent := float64(currentCmds) / float64(message_limit) * 100.0
+			completionPercentStr = fmt.Sprintf("[%3.1f%%]", completionPercent)
+		}
+		errorPercent := float64(currentErrs) / float64(currentCmds) * 100.0
 
-				fmt.Printf("%25.0fs %s %25d %25d [%3.1f%%] %25.2f %19.3f (%3.3f) %20.3f (%3.3f)\t", time.Since(start).Seconds(), completionPercentStr, currentCmds, currentErrs, errorPercent, messageRate, instantP50, p50, instantP50RunTimeGraph, p50RunTimeGraph)
-				fmt.Printf("\r")
-				if message_limit > 0 && currentCmds >= message_limit && !loop {
-					return true
-				}
-				// The locks we acquire here do not affect the clients
-				resetInstantHistograms()
-				break
+		instantHistogramsResetMutex.Lock()
+		p50 := float64(clientSide_AllQueries_OverallLatencies.ValueAtQuantile(50.0)) / 1000.0
+		p50RunTimeGraph := float64(serverSide_AllQueries_GraphInternalTime_OverallLatencies.ValueAtQuantile(50.0)) / 1000.0
+		instantP50 := float64(clientSide_AllQueries_InstantLatencies.ValueAtQuantile(50.0)) / 1000.0
+		instantP50RunTimeGraph := float64(serverSide_AllQueries_GraphInternalTime_InstantLatencies.ValueAtQuantile(50.0)) / 1000.0
+		if intervalOutput != nil {
+			if err := intervalOutput.outputInterval("client", clientSide_AllQueries_InstantLatencies, prevTime, now); err != nil {
+				log.Printf("Unable to output the interval histograms. Error: %v\n", err)
+			}
+			if err := intervalOutput.outputInterval("graph-internal", serverSide_AllQueries_GraphInternalTime_InstantLatencies, prevTime, now); err != nil {
+				log.Printf("Unable to output the interval histograms. Error: %v\n", err)
 			}
+		}
+		if runTimeStats != nil {
+			runTimeStats[now.UnixMilli()] = GetClientRunTimeStats(queries, currentCmds, currentErrs, messageRate)
+		}
+		var samples []tickSample
+		if series != nil {
+			samples = series.getSamples(took, currentCmds, currentErrs)
+		}
+		instantHistogramsResetMutex.Unlock()
+		if currentCmds != 0 {
+			messageRateTs = append(messageRateTs, messageRate)
+		}
+		prevMessageCount = currentCmds
+		prevTime = now
+		if series != nil {
+			series.add(samples, now)
+		}
 
-		case <-c:
-			fmt.Println("\nReceived Ctrl-c - shutting down cli updater go-routine")
-			return false
+		fmt.Fprintf(progress, "%25.0fs %s %25d %25d [%3.1f%%] %25.2f %19.3f (%3.3f) %20.3f (%3.3f)\t", time.Since(start).Seconds(), completionPercentStr, currentCmds, currentErrs, errorPercent, messageRate, instantP50, p50, instantP50RunTimeGraph, p50RunTimeGraph)
+		fmt.Fprintf(progress, "\r")
+		if finished || (message_limit > 0 && currentCmds >= message_limit && !loop) {
+			return true
 		}
+		// The locks we acquire here do not affect the clients
+		resetInstantHistograms()
 	}
 }
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// compareThresholds holds the max allowed regressions, in percentage, of a candidate vs the baseline
+type compareThresholds struct {
+	maxRateRegression                 float64
+	maxClientLatencyRegression        float64
+	maxGraphInternalLatencyRegression float64
+	// in percentage points of the errors vs the issued queries
+	maxErrorRateIncrease float64
+	quantiles            []string
+}
+
+// comparisonLine is the comparison of a single metric of a query between the baseline and a candidate.
+// Delta is in percentage of the baseline value, and NaN when it can not be computed
+type comparisonLine struct {
+	Query            string
+	Metric           string
+	Baseline         float64
+	Candidate        float64
+	Delta            float64
+	BaselineMissing  bool
+	CandidateMissing bool
+	Regression       bool
+}
+
+func loadTestResult(fileName string) (testResult *TestResult, err error) {
+	var content []byte
+	content, err = ioutil.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	testResult = &TestResult{}
+	err = json.Unmarshal(content, testResult)
+	if err == nil && testResult.ResultFormatVersion != resultFormatVersion {
+		log.Printf("%s was written with the result format version '%s', while the current one is '%s'. Some of the metrics might be missing or named differently\n", fileName, testResult.ResultFormatVersion, resultFormatVersion)
+	}
+	return
+}
+
+// getAlignedQueries returns the union of the queries of both maps, sorted, with the "Total" entry last
+func getAlignedQueries(baseline map[string]interface{}, candidate map[string]interface{}) []string {
+	seen := map[string]bool{}
+	queries := []string{}
+	for _, m := range []map[string]interface{}{baseline, candidate} {
+		for query := range m {
+			if !seen[query] && query != "Total" {
+				seen[query] = true
+				queries = append(queries, query)
+			}
+		}
+	}
+	sort.Strings(queries)
+	return append(queries, "Total")
+}
+
+// toFloat64Map converts a decoded json object into a map of float64 values, skipping non numeric ones
+func toFloat64Map(v interface{}) map[string]float64 {
+	m := map[string]float64{}
+	if raw, ok := v.(map[string]interface{}); ok {
+		for key, value := range raw {
+			if f, ok := value.(float64); ok {
+				m[key] = f
+			}
+		}
+	}
+	return m
+}
+
+func newComparisonLine(query, metric string, baseline float64, baselineFound bool, candidate float64, candidateFound bool) comparisonLine {
+	line := comparisonLine{Query: query, Metric: metric, Baseline: baseline, Candidate: candidate, Delta: math.NaN(), BaselineMissing: !baselineFound, CandidateMissing: !candidateFound}
+	if baselineFound && candidateFound {
+		if baseline != 0 {
+			line.Delta = (candidate - baseline) / baseline * 100.0
+		} else if candidate == 0 {
+			line.Delta = 0
+		}
+	}
+	return line
+}
+
+func compareRates(baseline, candidate map[string]interface{}, maxRegression float64) (lines []comparisonLine) {
+	lines = []comparisonLine{}
+	for _, query := range getAlignedQueries(baseline, candidate) {
+		b, bFound := baseline[query].(float64)
+		c, cFound := candidate[query].(float64)
+		line := newComparisonLine(query, "rate", b, bFound, c, cFound)
+		line.Regression = !math.IsNaN(line.Delta) && line.Delta < -maxRegression
+		lines = append(lines, line)
+	}
+	return
+}
+
+func compareLatencies(baseline, candidate map[string]interface{}, quantiles []string, maxRegression float64) (lines []comparisonLine) {
+	lines = []comparisonLine{}
+	for _, query := range getAlignedQueries(baseline, candidate) {
+		_, bQueryFound := baseline[query]
+		_, cQueryFound := candidate[query]
+		b := toFloat64Map(baseline[query])
+		c := toFloat64Map(candidate[query])
+		for _, quantile := range quantiles {
+			bValue, bFound := b[quantile]
+			cValue, cFound := c[quantile]
+			line := newComparisonLine(query, quantile, bValue, bQueryFound && bFound, cValue, cQueryFound && cFound)
+			line.Regression = !math.IsNaN(line.Delta) && line.Delta > maxRegression
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+func compareTotals(baseline, candidate map[string]interface{}, maxErrorRateIncrease float64) (lines []comparisonLine) {
+	lines = []comparisonLine{}
+	metrics := []string{"IssuedQueries", "Errors", "NodesCreated", "NodesDeleted", "LabelsAdded", "PropertiesSet", "RelationshipsCreated", "RelationshipsDeleted"}
+	for _, query := range getAlignedQueries(baseline, candidate) {
+		_, bQueryFound := baseline[query]
+		_, cQueryFound := candidate[query]
+		b := toFloat64Map(baseline[query])
+		c := toFloat64Map(candidate[query])
+		for _, metric := range metrics {
+			line := newComparisonLine(query, metric, b[metric], bQueryFound, c[metric], cQueryFound)
+			if metric == "Errors" && bQueryFound && cQueryFound {
+				line.Regression = getErrorRate(c)-getErrorRate(b) > maxErrorRateIncrease
+			}
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+// getErrorRate returns the percentage of errors vs the issued queries of a Totals entry
+func getErrorRate(totals map[string]float64) float64 {
+	if totals["IssuedQueries"] == 0 {
+		return 0
+	}
+	return totals["Errors"] / totals["IssuedQueries"] * 100.0
+}
+
+func countRegressions(lines []comparisonLine) (regressions int) {
+	for _, line := range lines {
+		if line.Regression {
+			regressions++
+		}
+	}
+	return
+}
+
+func printComparisonTable(title string, lines []comparisonLine, withMetric bool) {
+	writer := os.Stdout
+	fmt.Fprintf(writer, "## %s\n", title)
+	table := tablewriter.NewWriter(writer)
+	header := []string{"Query"}
+	if withMetric {
+		header = append(header, "Metric")
+	}
+	table.SetHeader(append(header, "Baseline", "Candidate", "Delta(%)", "Regression"))
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAutoWrapText(false)
+	for _, line := range lines {
+		row := []string{line.Query}
+		if withMetric {
+			row = append(row, line.Metric)
+		}
+		baseline := fmt.Sprintf("%.3f", line.Baseline)
+		if line.BaselineMissing {
+			baseline = "-"
+		}
+		candidate := fmt.Sprintf("%.3f", line.Candidate)
+		if line.CandidateMissing {
+			candidate = "-"
+		}
+		delta := "n/a"
+		if !math.IsNaN(line.Delta) {
+			delta = fmt.Sprintf("%+.2f", line.Delta)
+		}
+		table.Append(append(row, baseline, candidate, delta, fmt.Sprintf("%t", line.Regression)))
+	}
+	table.Render()
+}
+
+// flattenConfigs flattens the nested DBSpecificConfigs into "<section>.<name>" keys, e.g. GraphConfig.THREAD_COUNT.
+// List entries with a name ( e.g. the modules ) are keyed by it, so that their order does not matter
+func flattenConfigs(prefix string, v interface{}, flat map[string]string) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, element := range value {
+			flattenConfigs(prefix+"."+k, element, flat)
+		}
+	case []interface{}:
+		for i, element := range value {
+			key := fmt.Sprintf("%d", i)
+			if m, ok := element.(map[string]interface{}); ok && m["name"] != nil {
+				key = fmt.Sprint(m["name"])
+			}
+			flattenConfigs(prefix+"."+key, element, flat)
+		}
+	default:
+		flat[strings.TrimPrefix(prefix, ".")] = fmt.Sprint(value)
+	}
+}
+
+// getConfigDifferences returns the [config, baseline, candidate] rows of the server configs that differ, sorted by config
+func getConfigDifferences(baseline, candidate map[string]interface{}) (rows [][]string) {
+	baselineFlat, candidateFlat := map[string]string{}, map[string]string{}
+	flattenConfigs("", baseline, baselineFlat)
+	flattenConfigs("", candidate, candidateFlat)
+	for _, m := range []map[string]string{baselineFlat, candidateFlat} {
+		for config := range m {
+			if _, found := baselineFlat[config]; !found {
+				baselineFlat[config] = "-"
+			}
+			if _, found := candidateFlat[config]; !found {
+				candidateFlat[config] = "-"
+			}
+		}
+	}
+	rows = [][]string{}
+	for config, baselineValue := range baselineFlat {
+		if baselineValue != candidateFlat[config] {
+			rows = append(rows, []string{config, baselineValue, candidateFlat[config]})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	return
+}
+
+// compareResults prints the per query deltas of the candidate vs the baseline and returns the number of regressions
+func compareResults(baseline, candidate *TestResult, t compareThresholds) (regressions int) {
+	rates := compareRates(baseline.OverallQueryRates, candidate.OverallQueryRates, t.maxRateRegression)
+	clientLatencies := compareLatencies(baseline.OverallClientLatencies, candidate.OverallClientLatencies, t.quantiles, t.maxClientLatencyRegression)
+	graphInternalLatencies := compareLatencies(baseline.OverallGraphInternalLatencies, candidate.OverallGraphInternalLatencies, t.quantiles, t.maxGraphInternalLatencyRegression)
+	totals := compareTotals(baseline.Totals, candidate.Totals, t.maxErrorRateIncrease)
+	// server config differences are informative only, they do not count as regressions
+	if configDifferences := getConfigDifferences(baseline.DBSpecificConfigs, candidate.DBSpecificConfigs); len(configDifferences) > 0 {
+		fmt.Fprintf(os.Stdout, "## Server configuration differences\n")
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Config", "Baseline", "Candidate"})
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		table.SetAutoWrapText(false)
+		table.AppendBulk(configDifferences)
+		table.Render()
+	}
+	printComparisonTable("Overall query rates comparison", rates, false)
+	printComparisonTable("Overall Client Latency comparison (ms)", clientLatencies, true)
+	printComparisonTable("Overall RedisGraph Internal Execution Time comparison (ms)", graphInternalLatencies, true)
+	printComparisonTable("Totals comparison", totals, true)
+	regressions = countRegressions(rates) + countRegressions(clientLatencies) + countRegressions(graphInternalLatencies) + countRegressions(totals)
+	return
+}
+
+// runCompare implements the compare subcommand, returning the process exit code:
+// 0 if no regressions were detected, 1 otherwise
+func runCompare(args []string) int {
+	compareFlags := flag.NewFlagSet("compare", flag.ExitOnError)
+	maxRateRegression := compareFlags.Float64("max-rate-regression", 5, "Max allowed decrease, in percentage, of each query rate vs the baseline.")
+	maxClientLatencyRegression := compareFlags.Float64("max-latency-regression", 10, "Max allowed increase, in percentage, of each query client latency quantile vs the baseline.")
+	maxGraphInternalLatencyRegression := compareFlags.Float64("max-graph-internal-latency-regression", 10, "Max allowed increase, in percentage, of each query RedisGraph internal execution time quantile vs the baseline.")
+	maxErrorRateIncrease := compareFlags.Float64("max-error-rate-increase", 0, "Max allowed increase, in percentage points, of each query error rate vs the baseline.")
+	quantiles := compareFlags.String("quantiles", "q50,q95,q99", "Comma separated list of the latency quantiles to compare.")
+	compareFlags.Usage = func() {
+		fmt.Fprintf(compareFlags.Output(), "Usage of compare: redisgraph-benchmark-go compare [options] <baseline.json> <candidate.json> [<candidate.json> ...]\n")
+		compareFlags.PrintDefaults()
+	}
+	compareFlags.Parse(args)
+	files := compareFlags.Args()
+	if len(files) < 2 {
+		compareFlags.Usage()
+		log.Fatalf("You need to specify at least a baseline and a candidate results file.")
+	}
+	thresholds := compareThresholds{
+		maxRateRegression:                 *maxRateRegression,
+		maxClientLatencyRegression:        *maxClientLatencyRegression,
+		maxGraphInternalLatencyRegression: *maxGraphInternalLatencyRegression,
+		maxErrorRateIncrease:              *maxErrorRateIncrease,
+		quantiles:                         strings.Split(*quantiles, ","),
+	}
+	baseline, err := loadTestResult(files[0])
+	if err != nil {
+		log.Fatalf("Unable to load baseline results file %s. Error: %v", files[0], err)
+	}
+	totalRegressions := 0
+	for _, candidateFile := range files[1:] {
+		candidate, err := loadTestResult(candidateFile)
+		if err != nil {
+			log.Fatalf("Unable to load candidate results file %s. Error: %v", candidateFile, err)
+		}
+		fmt.Fprintf(os.Stdout, "# Comparing %s ( baseline ) vs %s\n", files[0], candidateFile)
+		regressions := compareResults(baseline, candidate, thresholds)
+		log.Printf("Detected %d regressions on %s vs %s\n", regressions, candidateFile, files[0])
+		totalRegressions += regressions
+	}
+	if totalRegressions > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_getAlignedQueries(t *testing.T) {
+	baseline := map[string]interface{}{"CREATE (n)": 1.0, "MATCH (n) RETURN n": 1.0, "Total": 2.0}
+	candidate := map[string]interface{}{"CREATE (n)": 1.0, "MATCH (n) RETURN count(n)": 1.0, "Total": 2.0}
+	want := []string{"CREATE (n)", "MATCH (n) RETURN count(n)", "MATCH (n) RETURN n", "Total"}
+	if got := getAlignedQueries(baseline, candidate); !reflect.DeepEqual(got, want) {
+		t.Errorf("getAlignedQueries() = %v, want %v", got, want)
+	}
+}
+
+func Test_compareRates(t *testing.T) {
+	baseline := map[string]interface{}{"CREATE (n)": 1000.0, "MATCH (n) RETURN n": 1000.0, "Total": 2000.0}
+	candidate := map[string]interface{}{"CREATE (n)": 960.0, "MATCH (n) RETURN n": 900.0, "Total": 1860.0}
+	wantDeltas := []float64{-4, -10, -7}
+	wantRegressions := []bool{false, true, true}
+	lines := compareRates(baseline, candidate, 5)
+	for i, line := range lines {
+		if math.Abs(line.Delta-wantDeltas[i]) > 1e-9 || line.Regression != wantRegressions[i] {
+			t.Errorf("compareRates()[%d] = %+v, want delta %v regression %v", i, line, wantDeltas[i], wantRegressions[i])
+		}
+	}
+}
+
+func Test_compareLatencies(t *testing.T) {
+	baseline := map[string]interface{}{"CREATE (n)": map[string]interface{}{"q50": 1.0, "q99": 2.0}, "Total": map[string]interface{}{"q50": 1.0, "q99": 2.0}}
+	candidate := map[string]interface{}{"Total": map[string]interface{}{"q50": 1.05, "q99": 3.0}}
+	lines := compareLatencies(baseline, candidate, []string{"q50", "q99"}, 10)
+	if len(lines) != 4 {
+		t.Fatalf("compareLatencies() returned %d lines, want 4", len(lines))
+	}
+	if !lines[0].CandidateMissing || !math.IsNaN(lines[0].Delta) || lines[0].Regression {
+		t.Errorf("compareLatencies()[0] = %+v, want a missing candidate without regression", lines[0])
+	}
+	if lines[2].Regression {
+		t.Errorf("compareLatencies()[2] = %+v, want no regression", lines[2])
+	}
+	if !lines[3].Regression || math.Abs(lines[3].Delta-50) > 1e-9 {
+		t.Errorf("compareLatencies()[3] = %+v, want a 50%% regression", lines[3])
+	}
+}
+
+func Test_compareTotals(t *testing.T) {
+	baseline := map[string]interface{}{"Total": map[string]interface{}{"IssuedQueries": 1000.0, "Errors": 0.0}}
+	tests := []struct {
+		name                 string
+		candidateErrors      float64
+		maxErrorRateIncrease float64
+		wantRegression       bool
+	}{
+		{"no-errors", 0, 0, false},
+		{"errors", 1, 0, true},
+		{"errors-within-threshold", 1, 0.5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidate := map[string]interface{}{"Total": map[string]interface{}{"IssuedQueries": 1000.0, "Errors": tt.candidateErrors}}
+			if got := countRegressions(compareTotals(baseline, candidate, tt.maxErrorRateIncrease)) > 0; got != tt.wantRegression {
+				t.Errorf("compareTotals() regression = %v, want %v", got, tt.wantRegression)
+			}
+		})
+	}
+}
+
+func Test_getConfigDifferences(t *testing.T) {
+	baseline := map[string]interface{}{
+		"RedisGraphVersion": 20811.0,
+		"GraphConfig":       map[string]interface{}{"THREAD_COUNT": 8.0, "CACHE_SIZE": 25.0},
+		"Modules":           []interface{}{map[string]interface{}{"name": "search", "ver": 20405.0}, map[string]interface{}{"name": "graph", "ver": 20811.0}},
+	}
+	candidate := map[string]interface{}{
+		"RedisGraphVersion": 20811.0,
+		"GraphConfig":       map[string]interface{}{"THREAD_COUNT": 16.0, "CACHE_SIZE": 25.0, "TIMEOUT": 1000.0},
+		"Modules":           []interface{}{map[string]interface{}{"name": "graph", "ver": 20811.0}, map[string]interface{}{"name": "search", "ver": 20405.0}},
+	}
+	want := [][]string{{"GraphConfig.THREAD_COUNT", "8", "16"}, {"GraphConfig.TIMEOUT", "-", "1000"}}
+	if got := getConfigDifferences(baseline, candidate); !reflect.DeepEqual(got, want) {
+		t.Errorf("getConfigDifferences() = %v, want %v", got, want)
+	}
+}
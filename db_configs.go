@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// infoServerFields are the "INFO server" fields captured into the results
+var infoServerFields = []string{"redis_version", "redis_git_sha1", "redis_build_id", "redis_mode", "os", "arch_bits", "multiplexing_api", "gcc_version", "hz", "configured_hz", "io_threads_active", "executable", "config_file"}
+
+// infoMemoryFields are the "INFO memory" fields captured into the results
+var infoMemoryFields = []string{"used_memory", "used_memory_human", "used_memory_rss", "used_memory_peak", "used_memory_dataset", "total_system_memory", "maxmemory", "maxmemory_human", "maxmemory_policy", "mem_fragmentation_ratio", "mem_allocator"}
+
+// parseInfoFields extracts the given fields of an INFO reply. Fields missing on the reply are skipped
+func parseInfoFields(info string, fields []string) map[string]string {
+	wanted := map[string]bool{}
+	for _, field := range fields {
+		wanted[field] = true
+	}
+	values := map[string]string{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 && wanted[kv[0]] {
+			values[kv[0]] = kv[1]
+		}
+	}
+	return values
+}
+
+// toResultValue converts a redis reply into a json friendly value, with the bulk strings as strings and the arrays converted recursively
+func toResultValue(reply interface{}) interface{} {
+	switch v := reply.(type) {
+	case []byte:
+		return string(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, element := range v {
+			values[i] = toResultValue(element)
+		}
+		return values
+	case redis.Error:
+		return v.Error()
+	}
+	return reply
+}
+
+// parseGraphConfigs converts the "GRAPH.CONFIG GET *" reply, an array of [name, value] pairs, into a map
+// As on the redis helpers, a non nil replyErr is returned as is
+func parseGraphConfigs(reply interface{}, replyErr error) (configs map[string]interface{}, err error) {
+	var pairs []interface{}
+	pairs, err = redis.Values(reply, replyErr)
+	if err != nil {
+		return
+	}
+	configs = map[string]interface{}{}
+	for _, rawPair := range pairs {
+		var pair []interface{}
+		pair, err = redis.Values(rawPair, nil)
+		if err != nil {
+			return
+		}
+		if len(pair) != 2 {
+			err = fmt.Errorf("expected a [name, value] pair, got %d elements", len(pair))
+			return
+		}
+		var name string
+		name, err = redis.String(pair[0], nil)
+		if err != nil {
+			return
+		}
+		configs[name] = toResultValue(pair[1])
+	}
+	return
+}
+
+// parseModuleList converts the "MODULE LIST" reply into a list of maps, one per module, keyed by field name.
+// Each module is either an array of alternating field names and values, or a map on RESP3 replies.
+// As on the redis helpers, a non nil replyErr is returned as is
+func parseModuleList(reply interface{}, replyErr error) (modules []map[string]interface{}, err error) {
+	var rawModules []interface{}
+	rawModules, err = redis.Values(reply, replyErr)
+	if err != nil {
+		return
+	}
+	modules = make([]map[string]interface{}, 0, len(rawModules))
+	for _, rawModule := range rawModules {
+		module := map[string]interface{}{}
+		switch moduleInfo := rawModule.(type) {
+		case map[string]interface{}:
+			for field, value := range moduleInfo {
+				module[field] = toResultValue(value)
+			}
+		case map[interface{}]interface{}:
+			for field, value := range moduleInfo {
+				module[fmt.Sprint(toResultValue(field))] = toResultValue(value)
+			}
+		default:
+			var fields []interface{}
+			fields, err = redis.Values(rawModule, nil)
+			if err != nil {
+				return
+			}
+			for i := 0; i+1 < len(fields); i += 2 {
+				var field string
+				field, err = redis.String(fields[i], nil)
+				if err != nil {
+					return
+				}
+				module[field] = toResultValue(fields[i+1])
+			}
+		}
+		modules = append(modules, module)
+	}
+	return
+}
+
+// graphModuleFlavors maps the MODULE LIST names of RedisGraph and its forks to the project name
+var graphModuleFlavors = map[string]string{"graph": "RedisGraph", "redisgraph": "RedisGraph", "falkordb": "FalkorDB"}
+
+// graphModule is the graph module loaded on the server
+type graphModule struct {
+	Name    string
+	Flavor  string
+	Version int64
+	// major.minor.patch, decoded from the integer version
+	SemanticVersion string
+}
+
+// getSemanticVersion decodes the integer module version into major.minor.patch, e.g. 20811 into 2.8.11
+func getSemanticVersion(version int64) string {
+	return fmt.Sprintf("%d.%d.%d", version/10000, (version/100)%100, version%100)
+}
+
+// findGraphModule returns the graph module among the parsed MODULE LIST entries.
+// FalkorDB keeps the "graph" module name, so it is also recognised by the module path
+func findGraphModule(modules []map[string]interface{}) (module graphModule, found bool) {
+	for _, m := range modules {
+		name := fmt.Sprint(m["name"])
+		flavor, known := graphModuleFlavors[strings.ToLower(name)]
+		if !known {
+			continue
+		}
+		if path, ok := m["path"].(string); ok && strings.Contains(strings.ToLower(path), "falkordb") {
+			flavor = "FalkorDB"
+		}
+		var version int64
+		var err error
+		switch v := m["ver"].(type) {
+		case int64:
+			version = v
+		case string:
+			version, err = strconv.ParseInt(v, 10, 64)
+		default:
+			err = fmt.Errorf("unexpected version type %T", v)
+		}
+		if err != nil {
+			continue
+		}
+		return graphModule{Name: name, Flavor: flavor, Version: version, SemanticVersion: getSemanticVersion(version)}, true
+	}
+	return
+}
+
+// getGraphModule returns the graph module info by issuing "MODULE LIST" and looking for RedisGraph ( or any of its forks ) by field name
+func getGraphModule(conn redis.Conn) (module graphModule, err error) {
+	var modules []map[string]interface{}
+	modules, err = parseModuleList(conn.Do("MODULE", "LIST"))
+	if err != nil {
+		return
+	}
+	var found bool
+	if module, found = findGraphModule(modules); !found {
+		err = fmt.Errorf("no graph module found among the %d loaded modules", len(modules))
+	}
+	return
+}
+
+// getServerConfigs captures the RedisGraph configuration, the loaded modules and the key INFO server and memory fields.
+// Each of them is optional: the errors are logged and the remaining ones are still captured
+func getServerConfigs(conn redis.Conn) map[string]interface{} {
+	configs := map[string]interface{}{}
+	graphConfigs, err := parseGraphConfigs(conn.Do("GRAPH.CONFIG", "GET", "*"))
+	if err != nil {
+		log.Printf("Unable to retrieve the RedisGraph configuration. Continuing anyway. Error: %v\n", err)
+	} else {
+		configs["GraphConfig"] = graphConfigs
+	}
+	modules, err := parseModuleList(conn.Do("MODULE", "LIST"))
+	if err != nil {
+		log.Printf("Unable to retrieve the modules list. Continuing anyway. Error: %v\n", err)
+	} else {
+		configs["Modules"] = modules
+	}
+	for _, section := range []struct {
+		name   string
+		key    string
+		fields []string
+	}{{"server", "ServerInfo", infoServerFields}, {"memory", "MemoryInfo", infoMemoryFields}} {
+		info, err := redis.String(conn.Do("INFO", section.name))
+		if err != nil {
+			log.Printf("Unable to retrieve INFO %s. Continuing anyway. Error: %v\n", section.name, err)
+			continue
+		}
+		configs[section.key] = parseInfoFields(info, section.fields)
+	}
+	return configs
+}
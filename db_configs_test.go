@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"reflect"
+	"testing"
+)
+
+func Test_parseInfoFields(t *testing.T) {
+	info := "# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmaxmemory:0\r\nmaxmemory_policy:noeviction\r\nmem_allocator:jemalloc-5.1.0\r\n"
+	want := map[string]string{"used_memory": "1048576", "maxmemory_policy": "noeviction", "mem_allocator": "jemalloc-5.1.0"}
+	if got := parseInfoFields(info, []string{"used_memory", "maxmemory_policy", "mem_allocator", "total_system_memory"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseInfoFields() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseGraphConfigs(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    interface{}
+		replyErr error
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		{"configs", []interface{}{
+			[]interface{}{[]byte("THREAD_COUNT"), int64(8)},
+			[]interface{}{[]byte("CACHE_SIZE"), int64(25)},
+			[]interface{}{[]byte("TIMEOUT"), int64(0)},
+		}, nil, map[string]interface{}{"THREAD_COUNT": int64(8), "CACHE_SIZE": int64(25), "TIMEOUT": int64(0)}, false},
+		{"string-values", []interface{}{[]interface{}{[]byte("QUERY_MEM_CAPACITY"), []byte("unlimited")}}, nil, map[string]interface{}{"QUERY_MEM_CAPACITY": "unlimited"}, false},
+		{"unknown-command", redis.Error("ERR unknown command 'GRAPH.CONFIG'"), nil, nil, true},
+		{"connection-error", nil, fmt.Errorf("connection refused"), nil, true},
+		{"not-a-pair", []interface{}{[]interface{}{[]byte("THREAD_COUNT")}}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGraphConfigs(tt.reply, tt.replyErr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGraphConfigs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGraphConfigs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseModuleList(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{[]byte("name"), []byte("graph"), []byte("ver"), int64(20811)},
+		[]interface{}{[]byte("name"), []byte("search"), []byte("ver"), int64(20405), []byte("path"), []byte("/usr/lib/redis/modules/redisearch.so"), []byte("args"), []interface{}{[]byte("MAXSEARCHRESULTS"), []byte("1000")}},
+	}
+	want := []map[string]interface{}{
+		{"name": "graph", "ver": int64(20811)},
+		{"name": "search", "ver": int64(20405), "path": "/usr/lib/redis/modules/redisearch.so", "args": []interface{}{"MAXSEARCHRESULTS", "1000"}},
+	}
+	got, err := parseModuleList(reply, nil)
+	if err != nil {
+		t.Fatalf("parseModuleList() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseModuleList() = %v, want %v", got, want)
+	}
+	if _, err = parseModuleList(nil, fmt.Errorf("connection refused")); err == nil {
+		t.Errorf("parseModuleList() expected the reply error to be returned")
+	}
+}
+
+func Test_parseModuleList_resp3(t *testing.T) {
+	reply := []interface{}{map[interface{}]interface{}{"name": []byte("graph"), "ver": int64(20811), "path": []byte("/usr/lib/redis/modules/redisgraph.so"), "args": []interface{}{}}}
+	want := []map[string]interface{}{{"name": "graph", "ver": int64(20811), "path": "/usr/lib/redis/modules/redisgraph.so", "args": []interface{}{}}}
+	got, err := parseModuleList(reply, nil)
+	if err != nil {
+		t.Fatalf("parseModuleList() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseModuleList() = %v, want %v", got, want)
+	}
+}
+
+func Test_getSemanticVersion(t *testing.T) {
+	tests := []struct {
+		version int64
+		want    string
+	}{
+		{20811, "2.8.11"},
+		{21000, "2.10.0"},
+		{40002, "4.0.2"},
+		{999999, "99.99.99"},
+		{0, "0.0.0"},
+	}
+	for _, tt := range tests {
+		if got := getSemanticVersion(tt.version); got != tt.want {
+			t.Errorf("getSemanticVersion(%d) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func Test_findGraphModule(t *testing.T) {
+	tests := []struct {
+		name      string
+		modules   []map[string]interface{}
+		want      graphModule
+		wantFound bool
+	}{
+		{"redisgraph", []map[string]interface{}{{"name": "search", "ver": int64(20405)}, {"name": "graph", "ver": int64(20811)}}, graphModule{"graph", "RedisGraph", 20811, "2.8.11"}, true},
+		{"falkordb-by-name", []map[string]interface{}{{"name": "falkordb", "ver": int64(40002)}}, graphModule{"falkordb", "FalkorDB", 40002, "4.0.2"}, true},
+		{"falkordb-by-path", []map[string]interface{}{{"name": "graph", "ver": int64(40002), "path": "/var/lib/falkordb/bin/falkordb.so", "args": []interface{}{}}}, graphModule{"graph", "FalkorDB", 40002, "4.0.2"}, true},
+		{"string-version", []map[string]interface{}{{"name": "GRAPH", "ver": "20811"}}, graphModule{"GRAPH", "RedisGraph", 20811, "2.8.11"}, true},
+		{"no-graph-module", []map[string]interface{}{{"name": "search", "ver": int64(20405)}}, graphModule{}, false},
+		{"no-modules", []map[string]interface{}{}, graphModule{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := findGraphModule(tt.modules)
+			if found != tt.wantFound || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findGraphModule() = %+v, %v, want %+v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
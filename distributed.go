@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// time given to all the agents to receive their workload before starting it simultaneously
+const agentStartDelay = 2 * time.Second
+
+// agentRunRequest is the workload share and connection settings the coordinator sends to each agent
+type agentRunRequest struct {
+	AgentIndex            int       `json:"AgentIndex"`
+	GraphKey              string    `json:"GraphKey"`
+	Network               string    `json:"Network"`
+	ConnectionStr         string    `json:"ConnectionStr"`
+	Endpoints             []string  `json:"Endpoints"`
+	Password              string    `json:"Password"`
+	TlsCaCertFile         string    `json:"TlsCaCertFile"`
+	ContinueOnError       bool      `json:"ContinueOnError"`
+	Debug                 int       `json:"Debug"`
+	RandomSeed            int64     `json:"RandomSeed"`
+	RandomIntMin          int64     `json:"RandomIntMin"`
+	RandomIntMax          int64     `json:"RandomIntMax"`
+	Queries               []string  `json:"Queries"`
+	QueryIsReadOnly       []bool    `json:"QueryIsReadOnly"`
+	CmdRates              []float64 `json:"CmdRates"`
+	Clients               uint64    `json:"Clients"`
+	NumberRequests        uint64    `json:"NumberRequests"`
+	DurationMillis        int64     `json:"DurationMillis"`
+	Rps                   int64     `json:"Rps"`
+	Pipeline              uint64    `json:"Pipeline"`
+	WarmupRequests        uint64    `json:"WarmupRequests"`
+	WarmupTimeMillis      int64     `json:"WarmupTimeMillis"`
+	ReportingPeriodMillis int64     `json:"ReportingPeriodMillis"`
+	// unix time in milliseconds at which the agent starts the workload
+	StartAt int64 `json:"StartAt"`
+}
+
+// agentTick holds the interval histograms of a single agent reporting period.
+// Commands and Errors are the totals since the start of the run
+type agentTick struct {
+	StartTime                     int64  `json:"StartTime"`
+	EndTime                       int64  `json:"EndTime"`
+	Commands                      uint64 `json:"Commands"`
+	Errors                        uint64 `json:"Errors"`
+	EncodedClientHistogram        string `json:"EncodedClientHistogram"`
+	EncodedGraphInternalHistogram string `json:"EncodedGraphInternalHistogram"`
+}
+
+type agentStatus struct {
+	Running bool        `json:"Running"`
+	Ticks   []agentTick `json:"Ticks"`
+	// only set once the run has finished
+	Result *TestResult `json:"Result"`
+}
+
+// benchmarkAgent runs the workloads received from a coordinator, one at a time given the benchmark stats are global
+type benchmarkAgent struct {
+	// when set, the coordinator requests need to carry it as a bearer token
+	token         string
+	mutex         sync.Mutex
+	running       bool
+	stop          chan struct{}
+	stopRequested bool
+	ticks         []agentTick
+	pendingClient *agentTick
+	result        *TestResult
+}
+
+// authorize rejects the requests that don't carry the agent token, if any
+func (a *benchmarkAgent) authorize(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+a.token)) != 1 {
+			http.Error(w, "invalid or missing agent token", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// outputInterval keeps the interval histograms of each tick until the coordinator polls them
+func (a *benchmarkAgent) outputInterval(tag string, histogram *hdrhistogram.Histogram, intervalStart time.Time, intervalEnd time.Time) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	switch tag {
+	case "client":
+		a.pendingClient = &agentTick{
+			StartTime:              intervalStart.UnixNano() / 1000000,
+			EndTime:                intervalEnd.UnixNano() / 1000000,
+			Commands:               atomic.LoadUint64(&totalCommands),
+			Errors:                 atomic.LoadUint64(&totalErrors),
+			EncodedClientHistogram: encodeHistogram(histogram),
+		}
+	case "graph-internal":
+		if a.pendingClient != nil {
+			a.pendingClient.EncodedGraphInternalHistogram = encodeHistogram(histogram)
+			a.ticks = append(a.ticks, *a.pendingClient)
+			a.pendingClient = nil
+		}
+	}
+	return nil
+}
+
+func (a *benchmarkAgent) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	var req agentRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid run request: %v", err), http.StatusBadRequest)
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.running {
+		http.Error(w, "the agent is already running a benchmark", http.StatusConflict)
+		return
+	}
+	a.running = true
+	a.stop = make(chan struct{})
+	a.stopRequested = false
+	a.ticks = []agentTick{}
+	a.pendingClient = nil
+	a.result = nil
+	go a.run(req, a.stop)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleStop interrupts the running benchmark as Ctrl-c does. Its partial result is reported via the status
+func (a *benchmarkAgent) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.running && !a.stopRequested {
+		log.Println("Received a stop request from the coordinator")
+		close(a.stop)
+		a.stopRequested = true
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *benchmarkAgent) handleStatus(w http.ResponseWriter, r *http.Request) {
+	from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+	a.mutex.Lock()
+	status := agentStatus{Running: a.running, Ticks: []agentTick{}, Result: a.result}
+	if from >= 0 && from < len(a.ticks) {
+		status.Ticks = append(status.Ticks, a.ticks[from:]...)
+	}
+	a.mutex.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (a *benchmarkAgent) run(req agentRunRequest, stop chan struct{}) {
+	// each agent uses a different seed, otherwise all of them would issue the exact same commands
+	rand.Seed(req.RandomSeed + int64(req.AgentIndex))
+	runner := &benchmarkRunner{
+		graphKey:        req.GraphKey,
+		network:         req.Network,
+		connectionStr:   req.ConnectionStr,
+		password:        req.Password,
+		tlsCaCertFile:   req.TlsCaCertFile,
+		endpoints:       req.Endpoints,
+		continueOnError: req.ContinueOnError,
+		debug:           req.Debug,
+		randomIntMin:    req.RandomIntMin,
+		randLimit:       req.RandomIntMax - req.RandomIntMin,
+		cliUpdateTick:   time.Duration(req.ReportingPeriodMillis) * time.Millisecond,
+		intervalOutput:  a,
+		stop:            stop,
+	}
+	workload := benchmarkWorkload{
+		name:            fmt.Sprintf("agent %d", req.AgentIndex),
+		queries:         req.Queries,
+		queryIsReadOnly: req.QueryIsReadOnly,
+		cmdRates:        req.CmdRates,
+		cdf:             getCommandsCDF(req.CmdRates),
+		clients:         req.Clients,
+		numberRequests:  req.NumberRequests,
+		duration:        time.Duration(req.DurationMillis) * time.Millisecond,
+		rps:             req.Rps,
+		pipeline:        req.Pipeline,
+		warmupRequests:  req.WarmupRequests,
+		warmupTime:      time.Duration(req.WarmupTimeMillis) * time.Millisecond,
+	}
+	startAt := time.Unix(0, req.StartAt*int64(time.Millisecond))
+	log.Printf("Received workload with %d clients. Starting at %v\n", req.Clients, startAt)
+	select {
+	case <-time.After(time.Until(startAt)):
+	case <-stop:
+	}
+	result, _ := runner.run(workload)
+	a.mutex.Lock()
+	a.result = result
+	a.running = false
+	a.mutex.Unlock()
+}
+
+// runAgent implements the agent subcommand, serving the coordinator requests up until the process is stopped
+func runAgent(args []string) int {
+	agentFlags := flag.NewFlagSet("agent", flag.ExitOnError)
+	listen := agentFlags.String("listen", ":8181", "Address the agent listens on for the coordinator requests.")
+	token := agentFlags.String("token", "", "Shared token the coordinator requests need to carry ( see the coordinator -agent-token ). If empty the requests are not authenticated.")
+	tlsCertFile := agentFlags.String("tls-cert-file", "", "A PEM encoded certificate file. When specified along with -tls-key-file the agent serves HTTPS.")
+	tlsKeyFile := agentFlags.String("tls-key-file", "", "A PEM encoded private key file, of the -tls-cert-file certificate.")
+	agentFlags.Usage = func() {
+		fmt.Fprintf(agentFlags.Output(), "Usage of agent: redisgraph-benchmark-go agent [options]\n")
+		agentFlags.PrintDefaults()
+	}
+	agentFlags.Parse(args)
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		log.Fatalf("Both -tls-cert-file and -tls-key-file need to be specified to serve HTTPS")
+	}
+	if *token == "" {
+		log.Println("No -token specified: any client reaching the agent can run benchmarks through it, and receives the database password the coordinator sends")
+	}
+	agent := &benchmarkAgent{token: *token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", agent.authorize(agent.handleRun))
+	mux.HandleFunc("/stop", agent.authorize(agent.handleStop))
+	mux.HandleFunc("/status", agent.authorize(agent.handleStatus))
+	var err error
+	if *tlsCertFile != "" {
+		log.Printf("Agent listening on %s ( HTTPS )\n", *listen)
+		err = http.ListenAndServeTLS(*listen, *tlsCertFile, *tlsKeyFile, mux)
+	} else {
+		log.Printf("Agent listening on %s\n", *listen)
+		err = http.ListenAndServe(*listen, mux)
+	}
+	if err != nil {
+		log.Fatalf("Agent stopped. Error: %v", err)
+	}
+	return 0
+}
+
+// getAgentShare splits total evenly across the agents. The last agent also takes the remainder
+func getAgentShare(total uint64, agents int, agentIndex int) uint64 {
+	share := total / uint64(agents)
+	if agentIndex == agents-1 {
+		share += total % uint64(agents)
+	}
+	return share
+}
+
+// agentClient issues the coordinator requests to the agents
+type agentClient struct {
+	httpClient *http.Client
+	scheme     string
+	token      string
+}
+
+// newAgentClient returns a client of the agents. When a CA cert file is specified the agents are reached via HTTPS,
+// verifying their certificates against it
+func newAgentClient(token string, tlsCaCertFile string) (*agentClient, error) {
+	client := &agentClient{httpClient: &http.Client{Timeout: 10 * time.Second}, scheme: "http", token: token}
+	if tlsCaCertFile != "" {
+		caCert, err := ioutil.ReadFile(tlsCaCertFile)
+		if err != nil {
+			return nil, err
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no PEM encoded certificate found in %s", tlsCaCertFile)
+		}
+		client.httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: caCertPool}}
+		client.scheme = "https"
+	}
+	return client, nil
+}
+
+func (c *agentClient) do(method string, agent string, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s://%s%s", c.scheme, agent, path), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
+func (c *agentClient) start(agent string, req agentRunRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPost, agent, "/run", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *agentClient) stop(agent string) error {
+	resp, err := c.do(http.MethodPost, agent, "/stop", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (c *agentClient) status(agent string, from int) (status agentStatus, err error) {
+	resp, err := c.do(http.MethodGet, agent, fmt.Sprintf("/status?from=%d", from), nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status %s", resp.Status)
+		return
+	}
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	return
+}
+
+// mergeEncodedHistogramInto decodes the histogram and merges it into dst. Empty histograms are skipped
+func mergeEncodedHistogramInto(dst *hdrhistogram.Histogram, encoded string) {
+	if encoded == "" {
+		return
+	}
+	histogram, err := hdrhistogram.Decode([]byte(encoded))
+	if err != nil {
+		log.Printf("Unable to decode an agent histogram. Error: %v\n", err)
+		return
+	}
+	dst.Merge(histogram)
+}
+
+// loadGlobalsFromTestResult fills the global histograms and counters with the ones of the result,
+// so that the final summary and the SLOs of a distributed run are rendered as the ones of a local run
+func loadGlobalsFromTestResult(r *TestResult, queries []string) {
+	createRequiredGlobalStructs(len(queries), 0)
+	for i, query := range queries {
+		mergeEncodedHistogramInto(clientSide_PerQuery_OverallLatencies[i], r.EncodedClientHistograms[query])
+		mergeEncodedHistogramInto(serverSide_PerQuery_GraphInternalTime_OverallLatencies[i], r.EncodedGraphInternalHistograms[query])
+		if totals, ok := r.Totals[query].(map[string]uint64); ok {
+			errorsPerQuery[i] = totals["Errors"]
+			totalNodesCreatedPerQuery[i] = totals["NodesCreated"]
+			totalNodesDeletedPerQuery[i] = totals["NodesDeleted"]
+			totalLabelsAddedPerQuery[i] = totals["LabelsAdded"]
+			totalPropertiesSetPerQuery[i] = totals["PropertiesSet"]
+			totalRelationshipsCreatedPerQuery[i] = totals["RelationshipsCreated"]
+			totalRelationshipsDeletedPerQuery[i] = totals["RelationshipsDeleted"]
+		}
+	}
+	mergeEncodedHistogramInto(clientSide_AllQueries_OverallLatencies, r.EncodedClientHistograms["Total"])
+	mergeEncodedHistogramInto(serverSide_AllQueries_GraphInternalTime_OverallLatencies, r.EncodedGraphInternalHistograms["Total"])
+	totalCommands = r.IssuedCommands
+	totalErrors = CountTotal(errorsPerQuery)
+	totalNodesCreated = CountTotal(totalNodesCreatedPerQuery)
+	totalNodesDeleted = CountTotal(totalNodesDeletedPerQuery)
+	totalLabelsAdded = CountTotal(totalLabelsAddedPerQuery)
+	totalPropertiesSet = CountTotal(totalPropertiesSetPerQuery)
+	totalRelationshipsCreated = CountTotal(totalRelationshipsCreatedPerQuery)
+	totalRelationshipsDeleted = CountTotal(totalRelationshipsDeletedPerQuery)
+}
+
+// runDistributed splits the workload across the agents, starts them simultaneously and collects their per tick
+// histograms live, producing a single merged result. Agents connect to the same endpoints as the coordinator,
+// and their clocks are expected to be in sync given the start time is absolute. On Ctrl-c the agents are stopped,
+// and their partial results merged
+func runDistributed(agents []string, client *agentClient, runner *benchmarkRunner, w benchmarkWorkload, randomSeed int64) (testResult *TestResult, err error) {
+	startAt := time.Now().Add(agentStartDelay)
+	for i, agent := range agents {
+		req := agentRunRequest{
+			AgentIndex:            i,
+			GraphKey:              runner.graphKey,
+			Network:               runner.network,
+			ConnectionStr:         runner.connectionStr,
+			Endpoints:             runner.endpoints,
+			Password:              runner.password,
+			TlsCaCertFile:         runner.tlsCaCertFile,
+			ContinueOnError:       runner.continueOnError,
+			Debug:                 runner.debug,
+			RandomSeed:            randomSeed,
+			RandomIntMin:          runner.randomIntMin,
+			RandomIntMax:          runner.randomIntMin + runner.randLimit,
+			Queries:               w.queries,
+			QueryIsReadOnly:       w.queryIsReadOnly,
+			CmdRates:              w.cmdRates,
+			Clients:               getAgentShare(w.clients, len(agents), i),
+			NumberRequests:        getAgentShare(w.numberRequests, len(agents), i),
+			DurationMillis:        w.duration.Milliseconds(),
+			Rps:                   int64(getAgentShare(uint64(w.rps), len(agents), i)),
+			Pipeline:              w.pipeline,
+			WarmupRequests:        getAgentShare(w.warmupRequests, len(agents), i),
+			WarmupTimeMillis:      w.warmupTime.Milliseconds(),
+			ReportingPeriodMillis: runner.cliUpdateTick.Milliseconds(),
+			StartAt:               startAt.UnixNano() / 1000000,
+		}
+		if err = client.start(agent, req); err != nil {
+			err = fmt.Errorf("unable to start agent %s: %v", agent, err)
+			return
+		}
+		log.Printf("Agent %s will run %d clients and %d requests\n", agent, req.Clients, req.NumberRequests)
+	}
+
+	// listen for C-c
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	defer signal.Stop(c)
+
+	tick := time.NewTicker(runner.cliUpdateTick)
+	defer tick.Stop()
+	time.Sleep(time.Until(startAt))
+	overallClient := hdrhistogram.New(1, 90000000000, 4)
+	overallGraphInternal := hdrhistogram.New(1, 90000000000, 4)
+	fromTick := make([]int, len(agents))
+	agentCommands := make([]uint64, len(agents))
+	agentErrors := make([]uint64, len(agents))
+	results := make([]*TestResult, len(agents))
+	finishedAgents := 0
+	prevTime := startAt
+	prevCommands := uint64(0)
+	completed := true
+	progress := getProgressWriter()
+	fmt.Fprintf(progress, "%26s %7s %25s %25s %7s %25s %25s %26s\n", "Test time", " ", "Total Commands", "Total Errors", "", "Command Rate", "Client p50 with RTT(ms)", "Graph Internal Time p50 (ms)")
+	for finishedAgents < len(agents) {
+		select {
+		case <-tick.C:
+		case <-c:
+			if completed {
+				fmt.Fprintln(progress, "\nReceived Ctrl-c - stopping the agents and collecting their partial results")
+				completed = false
+				for _, agent := range agents {
+					if stopErr := client.stop(agent); stopErr != nil {
+						log.Printf("Unable to stop agent %s. Error: %v\n", agent, stopErr)
+					}
+				}
+			}
+		}
+		instantClient := hdrhistogram.New(1, 90000000000, 4)
+		instantGraphInternal := hdrhistogram.New(1, 90000000000, 4)
+		for i, agent := range agents {
+			if results[i] != nil {
+				continue
+			}
+			var status agentStatus
+			status, err = client.status(agent, fromTick[i])
+			if err != nil {
+				err = fmt.Errorf("unable to get the status of agent %s: %v", agent, err)
+				return
+			}
+			for _, agentTick := range status.Ticks {
+				mergeEncodedHistogramInto(instantClient, agentTick.EncodedClientHistogram)
+				mergeEncodedHistogramInto(instantGraphInternal, agentTick.EncodedGraphInternalHistogram)
+				agentCommands[i] = agentTick.Commands
+				agentErrors[i] = agentTick.Errors
+			}
+			fromTick[i] += len(status.Ticks)
+			if !status.Running && status.Result != nil {
+				results[i] = status.Result
+				agentCommands[i] = status.Result.IssuedCommands
+				finishedAgents++
+			}
+		}
+		overallClient.Merge(instantClient)
+		overallGraphInternal.Merge(instantGraphInternal)
+		now := time.Now()
+		if runner.intervalOutput != nil {
+			runner.intervalOutput.outputInterval("client", instantClient, prevTime, now)
+			runner.intervalOutput.outputInterval("graph-internal", instantGraphInternal, prevTime, now)
+		}
+		currentCmds := CountTotal(agentCommands)
+		currentErrs := CountTotal(agentErrors)
+		messageRate := calculateRateMetrics(int64(currentCmds), int64(prevCommands), now.Sub(prevTime))
+		errorPercent := 0.0
+		if currentCmds > 0 {
+			errorPercent = float64(currentErrs) / float64(currentCmds) * 100.0
+		}
+		fmt.Fprintf(progress, "%25.0fs %s %25d %25d [%3.1f%%] %25.2f %19.3f (%3.3f) %20.3f (%3.3f)\t", now.Sub(startAt).Seconds(), fmt.Sprintf("[%d/%d]", finishedAgents, len(agents)), currentCmds, currentErrs, errorPercent, messageRate, float64(instantClient.ValueAtQuantile(50.0))/1000.0, float64(overallClient.ValueAtQuantile(50.0))/1000.0, float64(instantGraphInternal.ValueAtQuantile(50.0))/1000.0, float64(overallGraphInternal.ValueAtQuantile(50.0))/1000.0)
+		fmt.Fprintf(progress, "\r")
+		prevTime = now
+		prevCommands = currentCmds
+	}
+
+	finishedResults := make([]*TestResult, 0, len(results))
+	for _, result := range results {
+		if result != nil {
+			finishedResults = append(finishedResults, result)
+		}
+	}
+	if len(finishedResults) == 0 {
+		testResult = NewTestResult("", uint(w.clients), w.numberRequests, uint64(w.rps), "distributed")
+		return
+	}
+	testResult, err = mergeTestResults(finishedResults)
+	if err != nil {
+		err = fmt.Errorf("unable to merge the agents results: %v", err)
+		return
+	}
+	testResult.TestDescription = fmt.Sprintf("distributed across %d agents", len(agents))
+	testResult.BenchmarkFullyRun = completed && testResult.BenchmarkFullyRun
+	testResult.Endpoints = runner.endpoints
+	duration := time.Duration(testResult.DurationMillis) * time.Millisecond
+	loadGlobalsFromTestResult(testResult, w.queries)
+	testResult.SLOs = evaluateSLOs(w.slos, w.queries, duration)
+	printFinalSummary(w.queries, []string{}, w.cmdRates, totalCommands, duration, testResult.SLOs, nil, nil)
+	return
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_getAgentShare(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      uint64
+		agents     int
+		agentIndex int
+		want       uint64
+	}{
+		{"even", 100, 2, 0, 50},
+		{"even-last", 100, 2, 1, 50},
+		{"remainder-first", 10, 3, 0, 3},
+		{"remainder-last", 10, 3, 2, 4},
+		{"zero", 0, 3, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getAgentShare(tt.total, tt.agents, tt.agentIndex); got != tt.want {
+				t.Errorf("getAgentShare() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_benchmarkAgent_status(t *testing.T) {
+	agent := &benchmarkAgent{}
+	histogram := hdrhistogram.New(1, 90000000000, 4)
+	histogram.RecordValue(1000)
+	now := time.Now()
+	agent.outputInterval("client", histogram, now.Add(-time.Second), now)
+	agent.outputInterval("graph-internal", histogram, now.Add(-time.Second), now)
+	agent.outputInterval("client", histogram, now, now.Add(time.Second))
+	// the tick is only complete once both histograms were output
+	agent.outputInterval("graph-internal", histogram, now, now.Add(time.Second))
+	agent.outputInterval("client", histogram, now.Add(time.Second), now.Add(2*time.Second))
+
+	tests := []struct {
+		from      string
+		wantTicks int
+	}{
+		{"0", 2},
+		{"1", 1},
+		{"2", 0},
+		{"10", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			agent.handleStatus(recorder, httptest.NewRequest(http.MethodGet, "/status?from="+tt.from, nil))
+			var status agentStatus
+			if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+				t.Fatalf("unable to decode the status. Error: %v", err)
+			}
+			if len(status.Ticks) != tt.wantTicks {
+				t.Errorf("handleStatus() returned %d ticks, want %d", len(status.Ticks), tt.wantTicks)
+			}
+		})
+	}
+}
+
+func Test_benchmarkAgent_handleRun(t *testing.T) {
+	agent := &benchmarkAgent{running: true}
+	recorder := httptest.NewRecorder()
+	agent.handleRun(recorder, httptest.NewRequest(http.MethodPost, "/run", strings.NewReader("{}")))
+	if recorder.Code != http.StatusConflict {
+		t.Errorf("handleRun() on a running agent returned %d, want %d", recorder.Code, http.StatusConflict)
+	}
+	recorder = httptest.NewRecorder()
+	agent.handleRun(recorder, httptest.NewRequest(http.MethodGet, "/run", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("handleRun() with GET returned %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+	agent.running = false
+	recorder = httptest.NewRecorder()
+	agent.handleRun(recorder, httptest.NewRequest(http.MethodPost, "/run", strings.NewReader("not json")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("handleRun() with an invalid body returned %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func Test_benchmarkAgent_handleStop(t *testing.T) {
+	stop := make(chan struct{})
+	agent := &benchmarkAgent{running: true, stop: stop}
+	for i := 0; i < 2; i++ {
+		// stopping twice does not close the stop channel again
+		recorder := httptest.NewRecorder()
+		agent.handleStop(recorder, httptest.NewRequest(http.MethodPost, "/stop", nil))
+		if recorder.Code != http.StatusAccepted {
+			t.Errorf("handleStop() returned %d, want %d", recorder.Code, http.StatusAccepted)
+		}
+	}
+	select {
+	case <-stop:
+	default:
+		t.Errorf("handleStop() did not close the stop channel of the running benchmark")
+	}
+	recorder := httptest.NewRecorder()
+	agent.handleStop(recorder, httptest.NewRequest(http.MethodGet, "/stop", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("handleStop() with GET returned %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func Test_agentClient_token(t *testing.T) {
+	agent := &benchmarkAgent{token: "secret", running: true, stop: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stop", agent.authorize(agent.handleStop))
+	mux.HandleFunc("/status", agent.authorize(agent.handleStatus))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	agentEndpoint := strings.TrimPrefix(server.URL, "http://")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"no-token", "", true},
+		{"wrong-token", "other", true},
+		{"token", "secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newAgentClient(tt.token, "")
+			if err != nil {
+				t.Fatalf("newAgentClient() error = %v", err)
+			}
+			if _, err := client.status(agentEndpoint, 0); (err != nil) != tt.wantErr {
+				t.Errorf("status() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := client.stop(agentEndpoint); (err != nil) != tt.wantErr {
+				t.Errorf("stop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if !agent.stopRequested {
+		t.Errorf("the authorized stop request did not stop the agent")
+	}
+}
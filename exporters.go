@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsD packets are kept under the usual ethernet MTU
+const statsdMaxPacketSize = 1432
+
+// exporterQueueSize is the max number of reporting periods queued on each of the -exporter sinks
+const exporterQueueSize = 100
+
+// benchmarkExporter is a live sink of the benchmark stats. Exporting never blocks the reporting period
+type benchmarkExporter interface {
+	// exportTick receives the samples of each reporting period
+	exportTick(samples []tickSample, now time.Time)
+	// exportResult receives the final results of each run, along with their samples
+	exportResult(result *TestResult, samples []tickSample, now time.Time)
+	close() error
+}
+
+// exporterSink writes the samples synchronously. It is run off the reporting period via queuedExporter
+type exporterSink interface {
+	writeTick(samples []tickSample, now time.Time) error
+	writeResult(result *TestResult, samples []tickSample, now time.Time) error
+	close() error
+}
+
+// newExporter creates the exporter of a -exporter parameter, in the format <type>[:<destination>]
+func newExporter(spec string) (benchmarkExporter, error) {
+	exporterType, destination := spec, ""
+	if pos := strings.Index(spec, ":"); pos >= 0 {
+		exporterType, destination = spec[:pos], spec[pos+1:]
+	}
+	var sink exporterSink
+	switch exporterType {
+	case "influx", "csv":
+		if destination == "" {
+			return nil, fmt.Errorf("the %s exporter requires a file name, e.g. %s:results.%s", exporterType, exporterType, exporterType)
+		}
+		f, err := os.Create(destination)
+		if err != nil {
+			return nil, err
+		}
+		if exporterType == "influx" {
+			sink = newInfluxExporter(f)
+		} else if sink, err = newCsvExporter(f); err != nil {
+			return nil, err
+		}
+	case "jsonl":
+		if destination == "" {
+			// the json lines would be mixed with the progress lines otherwise
+			jsonLinesOnStdout = true
+			sink = newJsonLinesExporter(nopWriteCloser{os.Stdout})
+			break
+		}
+		f, err := os.Create(destination)
+		if err != nil {
+			return nil, err
+		}
+		sink = newJsonLinesExporter(f)
+	case "statsd":
+		if destination == "" {
+			return nil, fmt.Errorf("the statsd exporter requires an address, e.g. statsd:127.0.0.1:8125")
+		}
+		conn, err := net.Dial("udp", destination)
+		if err != nil {
+			return nil, err
+		}
+		sink = &statsdExporter{conn: conn}
+	default:
+		return nil, fmt.Errorf("unknown exporter type '%s'. Either 'influx', 'csv', 'jsonl' or 'statsd'", exporterType)
+	}
+	return newQueuedExporter(spec, sink, exporterQueueSize), nil
+}
+
+// exporterRecord is either the samples of a reporting period or the final results of a run
+type exporterRecord struct {
+	samples []tickSample
+	result  *TestResult
+	now     time.Time
+}
+
+// queuedExporter writes to the sink on its own go-routine, so that a slow or broken sink does not delay the reporting periods.
+// Write errors are only logged once
+type queuedExporter struct {
+	name  string
+	sink  exporterSink
+	queue chan exporterRecord
+	wg    sync.WaitGroup
+	// reporting periods which did not fit on the queue, and records the sink failed to write
+	dropped uint64
+	failed  uint64
+}
+
+func newQueuedExporter(name string, sink exporterSink, queueSize int) *queuedExporter {
+	e := &queuedExporter{name: name, sink: sink, queue: make(chan exporterRecord, queueSize)}
+	e.wg.Add(1)
+	go e.processQueue()
+	return e
+}
+
+// exportTick never blocks. If the queue is full the reporting period is dropped
+func (e *queuedExporter) exportTick(samples []tickSample, now time.Time) {
+	select {
+	case e.queue <- exporterRecord{samples: samples, now: now}:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+}
+
+// exportResult waits for room on the queue, given the final results are only sent once per run
+func (e *queuedExporter) exportResult(result *TestResult, samples []tickSample, now time.Time) {
+	e.queue <- exporterRecord{samples: samples, result: result, now: now}
+}
+
+func (e *queuedExporter) processQueue() {
+	defer e.wg.Done()
+	for record := range e.queue {
+		var err error
+		if record.result != nil {
+			err = e.sink.writeResult(record.result, record.samples, record.now)
+		} else {
+			err = e.sink.writeTick(record.samples, record.now)
+		}
+		if err != nil {
+			if atomic.AddUint64(&e.failed, 1) == 1 {
+				log.Printf("Unable to write to the %s exporter. Continuing anyway, further errors are not logged. Error: %v\n", e.name, err)
+			}
+		}
+	}
+}
+
+// close waits for the queued records to be written and closes the sink
+func (e *queuedExporter) close() error {
+	close(e.queue)
+	e.wg.Wait()
+	dropped, failed := atomic.LoadUint64(&e.dropped), atomic.LoadUint64(&e.failed)
+	if dropped > 0 || failed > 0 {
+		log.Printf("The %s exporter dropped %d reporting periods given its queue was full, and failed to write %d records\n", e.name, dropped, failed)
+	}
+	return e.sink.close()
+}
+
+// nopWriteCloser does not close the underlying writer, e.g. stdout
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// getSortedLabels returns the label names of the sample, sorted
+func getSortedLabels(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// influxExporter writes the samples as InfluxDB line protocol, with the metric label as the measurement and the remaining labels as tags
+type influxExporter struct {
+	file   io.WriteCloser
+	writer *bufio.Writer
+}
+
+var influxTagReplacer = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func newInfluxExporter(file io.WriteCloser) *influxExporter {
+	return &influxExporter{file: file, writer: bufio.NewWriter(file)}
+}
+
+func getInfluxLine(sample tickSample, now time.Time) string {
+	line := influxTagReplacer.Replace(sample.labels["metric"])
+	for _, name := range getSortedLabels(sample.labels) {
+		if name == "metric" {
+			continue
+		}
+		line += fmt.Sprintf(",%s=%s", influxTagReplacer.Replace(name), influxTagReplacer.Replace(sample.labels[name]))
+	}
+	return fmt.Sprintf("%s value=%s %d\n", line, strconv.FormatFloat(sample.value, 'f', -1, 64), now.UnixNano())
+}
+
+func (e *influxExporter) writeTick(samples []tickSample, now time.Time) error {
+	for _, sample := range samples {
+		if _, err := e.writer.WriteString(getInfluxLine(sample, now)); err != nil {
+			return err
+		}
+	}
+	return e.writer.Flush()
+}
+
+func (e *influxExporter) writeResult(result *TestResult, samples []tickSample, now time.Time) error {
+	return e.writeTick(samples, now)
+}
+
+func (e *influxExporter) close() error {
+	if err := e.writer.Flush(); err != nil {
+		return err
+	}
+	return e.file.Close()
+}
+
+// csvExporter writes one line per sample
+type csvExporter struct {
+	file   io.WriteCloser
+	writer *csv.Writer
+}
+
+var csvExporterHeader = []string{"timestamp_ms", "run_name", "series", "metric", "query", "query_ro", "percentile", "value"}
+
+func newCsvExporter(file io.WriteCloser) (*csvExporter, error) {
+	e := &csvExporter{file: file, writer: csv.NewWriter(file)}
+	if err := e.writer.Write(csvExporterHeader); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvExporter) writeTick(samples []tickSample, now time.Time) error {
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	for _, sample := range samples {
+		if err := e.writer.Write([]string{timestamp, sample.labels["run_name"], sample.key, sample.labels["metric"], sample.labels["query"], sample.labels["query_ro"], sample.labels["percentile"], strconv.FormatFloat(sample.value, 'f', -1, 64)}); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExporter) writeResult(result *TestResult, samples []tickSample, now time.Time) error {
+	return e.writeTick(samples, now)
+}
+
+func (e *csvExporter) close() error {
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return err
+	}
+	return e.file.Close()
+}
+
+// jsonLinesExporter streams one json object per reporting period, and the full results of each run
+type jsonLinesExporter struct {
+	writer io.WriteCloser
+}
+
+type jsonLinesSample struct {
+	Series string            `json:"series"`
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
+}
+
+type jsonLinesRecord struct {
+	Type      string            `json:"type"`
+	Timestamp int64             `json:"timestamp"`
+	Samples   []jsonLinesSample `json:"samples,omitempty"`
+	Result    *TestResult       `json:"result,omitempty"`
+}
+
+func newJsonLinesExporter(writer io.WriteCloser) *jsonLinesExporter {
+	return &jsonLinesExporter{writer: writer}
+}
+
+func (e *jsonLinesExporter) write(record jsonLinesRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.writer, "%s\n", line)
+	return err
+}
+
+func (e *jsonLinesExporter) writeTick(samples []tickSample, now time.Time) error {
+	record := jsonLinesRecord{Type: "tick", Timestamp: now.UnixMilli(), Samples: make([]jsonLinesSample, len(samples))}
+	for i, sample := range samples {
+		record.Samples[i] = jsonLinesSample{Series: sample.key, Labels: sample.labels, Value: sample.value}
+	}
+	return e.write(record)
+}
+
+func (e *jsonLinesExporter) writeResult(result *TestResult, samples []tickSample, now time.Time) error {
+	return e.write(jsonLinesRecord{Type: "result", Timestamp: now.UnixMilli(), Result: result})
+}
+
+func (e *jsonLinesExporter) close() error {
+	return e.writer.Close()
+}
+
+// statsdExporter sends each sample as a gauge, named after the series with ':' replaced by '.'
+type statsdExporter struct {
+	conn net.Conn
+}
+
+var statsdNameReplacer = strings.NewReplacer(":", ".", " ", "_", "|", "_", "@", "_", "\n", "_")
+
+// getStatsdPackets packs the gauges into as few packets as possible, without exceeding statsdMaxPacketSize
+func getStatsdPackets(samples []tickSample) (packets []string) {
+	packets = []string{}
+	packet := ""
+	for _, sample := range samples {
+		gauge := fmt.Sprintf("%s:%s|g", statsdNameReplacer.Replace(sample.key), strconv.FormatFloat(sample.value, 'f', -1, 64))
+		if packet != "" && len(packet)+1+len(gauge) > statsdMaxPacketSize {
+			packets = append(packets, packet)
+			packet = ""
+		}
+		if packet != "" {
+			packet += "\n"
+		}
+		packet += gauge
+	}
+	if packet != "" {
+		packets = append(packets, packet)
+	}
+	return
+}
+
+// writeTick returns the first write error, e.g. when the StatsD port is unreachable
+func (e *statsdExporter) writeTick(samples []tickSample, now time.Time) (err error) {
+	for _, packet := range getStatsdPackets(samples) {
+		if _, writeErr := e.conn.Write([]byte(packet)); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return
+}
+
+func (e *statsdExporter) writeResult(result *TestResult, samples []tickSample, now time.Time) error {
+	return e.writeTick(samples, now)
+}
+
+func (e *statsdExporter) close() error {
+	return e.conn.Close()
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { jsonLinesOnStdout = false }()
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"influx", "influx:" + filepath.Join(dir, "run.influx"), false},
+		{"csv", "csv:" + filepath.Join(dir, "run.csv"), false},
+		{"jsonl", "jsonl", false},
+		{"jsonl file", "jsonl:" + filepath.Join(dir, "run.jsonl"), false},
+		{"statsd", "statsd:127.0.0.1:8125", false},
+		{"influx without file", "influx", true},
+		{"statsd without address", "statsd:", true},
+		{"unknown", "graphite:127.0.0.1:2003", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, err := newExporter(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newExporter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if exporter != nil {
+				exporter.close()
+			}
+		})
+	}
+}
+
+func Test_getInfluxLine(t *testing.T) {
+	sample := tickSample{key: "perf-run:query:0:messageRate", labels: map[string]string{"metric": "messageRate", "query": "MATCH (n) RETURN n", "run_name": "perf-run"}, value: 1500.5}
+	want := `messageRate,query=MATCH\ (n)\ RETURN\ n,run_name=perf-run value=1500.5 1600000000000000000` + "\n"
+	if got := getInfluxLine(sample, time.Unix(1600000000, 0)); got != want {
+		t.Errorf("getInfluxLine() = %v, want %v", got, want)
+	}
+}
+
+func Test_getStatsdPackets(t *testing.T) {
+	samples := []tickSample{{key: "perf-run:messageRate", value: 10}, {key: "perf-run:query:0:errorRate", value: 0.5}}
+	if got := getStatsdPackets(samples); len(got) != 1 || got[0] != "perf-run.messageRate:10|g\nperf-run.query.0.errorRate:0.5|g" {
+		t.Errorf("getStatsdPackets() = %q", got)
+	}
+	many := make([]tickSample, 200)
+	for i := range many {
+		many[i] = tickSample{key: "perf-run:query:0:overallIncludingRTT:p99.900", value: 1.234}
+	}
+	packets := getStatsdPackets(many)
+	total := 0
+	for _, packet := range packets {
+		if len(packet) > statsdMaxPacketSize {
+			t.Errorf("getStatsdPackets() packet size = %d, want at most %d", len(packet), statsdMaxPacketSize)
+		}
+		total += strings.Count(packet, "\n") + 1
+	}
+	if len(packets) < 2 || total != len(many) {
+		t.Errorf("getStatsdPackets() returned %d gauges in %d packets, want %d gauges in several packets", total, len(packets), len(many))
+	}
+}
+
+func Test_jsonLinesExporter(t *testing.T) {
+	var buffer bytes.Buffer
+	e := newJsonLinesExporter(nopWriteCloser{&buffer})
+	if err := e.writeTick([]tickSample{{key: "perf-run:messageRate", labels: map[string]string{"metric": "messageRate"}, value: 10}}, time.Unix(1, 0)); err != nil {
+		t.Fatalf("writeTick() error = %v", err)
+	}
+	if err := e.writeResult(&TestResult{IssuedCommands: 10}, nil, time.Unix(2, 0)); err != nil {
+		t.Fatalf("writeResult() error = %v", err)
+	}
+	nonEmpty := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(nonEmpty) != 2 {
+		t.Fatalf("jsonLinesExporter wrote %d lines, want 2", len(nonEmpty))
+	}
+	var tick jsonLinesRecord
+	if err := json.Unmarshal([]byte(nonEmpty[0]), &tick); err != nil || tick.Type != "tick" || tick.Timestamp != 1000 || len(tick.Samples) != 1 || tick.Samples[0].Value != 10 {
+		t.Errorf("jsonLinesExporter tick = %+v, error %v", tick, err)
+	}
+	var result jsonLinesRecord
+	if err := json.Unmarshal([]byte(nonEmpty[1]), &result); err != nil || result.Type != "result" || result.Result == nil || result.Result.IssuedCommands != 10 {
+		t.Errorf("jsonLinesExporter result = %+v, error %v", result, err)
+	}
+}
+
+func Test_tickSeries_getResultSamples(t *testing.T) {
+	defer func(previous []float64) { reportedPercentiles = previous }(reportedPercentiles)
+	reportedPercentiles = []float64{50}
+	w := benchmarkWorkload{queries: []string{"CREATE (n)"}, queryIsReadOnly: []bool{false}, clients: 1}
+	series := newTickSeries(nil, "perf-run", "", 0, w)
+	result := &TestResult{
+		OverallQueryRates:             map[string]interface{}{"CREATE (n)": 100.0, "Total": 100.0},
+		OverallClientLatencies:        map[string]interface{}{"CREATE (n)": map[string]float64{"q50": 1.5}, "Total": map[string]float64{"q50": 1.5}},
+		OverallGraphInternalLatencies: map[string]interface{}{},
+		Totals:                        map[string]interface{}{"CREATE (n)": map[string]uint64{"Errors": 2}},
+	}
+	got := map[string]float64{}
+	for _, sample := range series.getResultSamples(result) {
+		got[sample.key] = sample.value
+	}
+	want := map[string]float64{
+		"perf-run:result:query:0:messageRate":             100,
+		"perf-run:result:query:0:overallIncludingRTT:q50": 1.5,
+		"perf-run:result:query:0:Errors":                  2,
+		"perf-run:result:messageRate":                     100,
+		"perf-run:result:overallIncludingRTT:q50":         1.5,
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("getResultSamples() %s = %v, want %v", key, got[key], value)
+		}
+	}
+	if len(w.queries) != 1 {
+		t.Errorf("getResultSamples() modified the workload queries: %v", w.queries)
+	}
+}
+
+// blockingSink waits for release before writing each record, and fails every tick
+type blockingSink struct {
+	release chan struct{}
+	ticks   int
+	results int
+	closed  bool
+}
+
+func (s *blockingSink) writeTick(samples []tickSample, now time.Time) error {
+	<-s.release
+	s.ticks++
+	return fmt.Errorf("disk full")
+}
+
+func (s *blockingSink) writeResult(result *TestResult, samples []tickSample, now time.Time) error {
+	s.results++
+	return nil
+}
+
+func (s *blockingSink) close() error {
+	s.closed = true
+	return nil
+}
+
+func Test_queuedExporter(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	e := newQueuedExporter("test", sink, 2)
+	// the first tick is taken by the sink, the next ones are queued up until the queue is full and the remaining ones are dropped, without blocking
+	exported := make(chan struct{})
+	go func() {
+		for i := 0; i < 6; i++ {
+			e.exportTick(nil, time.Unix(int64(i), 0))
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(exported)
+	}()
+	select {
+	case <-exported:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("exportTick() blocked on a slow sink")
+	}
+	close(sink.release)
+	e.exportResult(&TestResult{}, nil, time.Unix(6, 0))
+	if err := e.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+	// depending on when the sink takes the first tick, 2 or 3 ticks make it
+	if sink.ticks < 2 || sink.ticks > 3 || sink.ticks+int(e.dropped) != 6 || e.failed != uint64(sink.ticks) {
+		t.Errorf("queuedExporter wrote %d ticks, dropped %d and failed %d, want 2 or 3 ticks written and failed, and the rest dropped", sink.ticks, e.dropped, e.failed)
+	}
+	if sink.results != 1 || !sink.closed {
+		t.Errorf("queuedExporter wrote %d results ( closed %v ), want 1 and the sink closed", sink.results, sink.closed)
+	}
+}
@@ -11,6 +11,7 @@ var totalCommands uint64
 var totalEmptyResultsets uint64
 var totalErrors uint64
 var errorsPerQuery []uint64
+var errorsPerEndpoint []uint64
 
 var totalNodesCreated uint64
 var totalNodesDeleted uint64
@@ -35,19 +36,54 @@ var serverSide_AllQueries_GraphInternalTime_OverallLatencies *hdrhistogram.Histo
 var clientSide_PerQuery_OverallLatencies []*hdrhistogram.Histogram
 var serverSide_PerQuery_GraphInternalTime_OverallLatencies []*hdrhistogram.Histogram
 
+// endpoint 0 is always the primary. read-only replicas ( if any ) follow it
+var clientSide_PerEndpoint_OverallLatencies []*hdrhistogram.Histogram
+var serverSide_PerEndpoint_GraphInternalTime_OverallLatencies []*hdrhistogram.Histogram
+
 // this mutex does not affect any of the client go-routines ( it's only to sync between main thread and datapoints processer go-routines )
 var instantHistogramsResetMutex sync.Mutex
 var clientSide_AllQueries_InstantLatencies *hdrhistogram.Histogram
 var serverSide_AllQueries_GraphInternalTime_InstantLatencies *hdrhistogram.Histogram
+var clientSide_PerQuery_InstantLatencies []*hdrhistogram.Histogram
+var serverSide_PerQuery_GraphInternalTime_InstantLatencies []*hdrhistogram.Histogram
+
+// warmup datapoints are kept apart, so that they do not pollute the benchmark results
+var warmupCommands uint64
+var warmupErrors uint64
+var warmupErrorsPerQuery []uint64
+var clientSide_AllQueries_WarmupLatencies *hdrhistogram.Histogram
+var serverSide_AllQueries_GraphInternalTime_WarmupLatencies *hdrhistogram.Histogram
+var clientSide_PerQuery_WarmupLatencies []*hdrhistogram.Histogram
+var serverSide_PerQuery_GraphInternalTime_WarmupLatencies []*hdrhistogram.Histogram
 
 var benchmarkQueries arrayStringParameters
 var benchmarkQueriesRO arrayStringParameters
 var benchmarkQueryRates arrayStringParameters
+var replicaEndpoints arrayStringParameters
+var sentinelEndpoints arrayStringParameters
+var sloSpecs arrayStringParameters
+var agentEndpoints arrayStringParameters
+var exporterSpecs arrayStringParameters
 
 const Inf = rate.Limit(math.MaxFloat64)
 
-func createRequiredGlobalStructs(totalDifferentCommands int) {
+// createRequiredGlobalStructs (re)creates all the counters and histograms of a benchmark run
+func createRequiredGlobalStructs(totalDifferentCommands int, totalEndpoints int) {
+	totalCommands = 0
+	totalEmptyResultsets = 0
+	totalErrors = 0
+	totalNodesCreated = 0
+	totalNodesDeleted = 0
+	totalLabelsAdded = 0
+	totalPropertiesSet = 0
+	totalRelationshipsCreated = 0
+	totalRelationshipsDeleted = 0
+	warmupCommands = 0
+	warmupErrors = 0
+
 	errorsPerQuery = make([]uint64, totalDifferentCommands)
+	errorsPerEndpoint = make([]uint64, totalEndpoints)
+	warmupErrorsPerQuery = make([]uint64, totalDifferentCommands)
 	totalNodesCreatedPerQuery = make([]uint64, totalDifferentCommands)
 	totalNodesDeletedPerQuery = make([]uint64, totalDifferentCommands)
 	totalLabelsAddedPerQuery = make([]uint64, totalDifferentCommands)
@@ -59,12 +95,29 @@ func createRequiredGlobalStructs(totalDifferentCommands int) {
 	clientSide_AllQueries_InstantLatencies = hdrhistogram.New(1, 90000000000, 4)
 	serverSide_AllQueries_GraphInternalTime_OverallLatencies = hdrhistogram.New(1, 90000000000, 4)
 	serverSide_AllQueries_GraphInternalTime_InstantLatencies = hdrhistogram.New(1, 90000000000, 4)
+	clientSide_AllQueries_WarmupLatencies = hdrhistogram.New(1, 90000000000, 4)
+	serverSide_AllQueries_GraphInternalTime_WarmupLatencies = hdrhistogram.New(1, 90000000000, 4)
 
 	clientSide_PerQuery_OverallLatencies = make([]*hdrhistogram.Histogram, totalDifferentCommands)
 	serverSide_PerQuery_GraphInternalTime_OverallLatencies = make([]*hdrhistogram.Histogram, totalDifferentCommands)
+	clientSide_PerQuery_InstantLatencies = make([]*hdrhistogram.Histogram, totalDifferentCommands)
+	serverSide_PerQuery_GraphInternalTime_InstantLatencies = make([]*hdrhistogram.Histogram, totalDifferentCommands)
+	clientSide_PerQuery_WarmupLatencies = make([]*hdrhistogram.Histogram, totalDifferentCommands)
+	serverSide_PerQuery_GraphInternalTime_WarmupLatencies = make([]*hdrhistogram.Histogram, totalDifferentCommands)
 	for i := 0; i < totalDifferentCommands; i++ {
 		clientSide_PerQuery_OverallLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
 		serverSide_PerQuery_GraphInternalTime_OverallLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
+		clientSide_PerQuery_InstantLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
+		serverSide_PerQuery_GraphInternalTime_InstantLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
+		clientSide_PerQuery_WarmupLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
+		serverSide_PerQuery_GraphInternalTime_WarmupLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
+	}
+
+	clientSide_PerEndpoint_OverallLatencies = make([]*hdrhistogram.Histogram, totalEndpoints)
+	serverSide_PerEndpoint_GraphInternalTime_OverallLatencies = make([]*hdrhistogram.Histogram, totalEndpoints)
+	for i := 0; i < totalEndpoints; i++ {
+		clientSide_PerEndpoint_OverallLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
+		serverSide_PerEndpoint_GraphInternalTime_OverallLatencies[i] = hdrhistogram.New(1, 90000000000, 4)
 	}
 }
 
@@ -72,5 +125,9 @@ func resetInstantHistograms() {
 	instantHistogramsResetMutex.Lock()
 	clientSide_AllQueries_InstantLatencies.Reset()
 	serverSide_AllQueries_GraphInternalTime_InstantLatencies.Reset()
+	for i := range clientSide_PerQuery_InstantLatencies {
+		clientSide_PerQuery_InstantLatencies[i].Reset()
+		serverSide_PerQuery_GraphInternalTime_InstantLatencies[i].Reset()
+	}
 	instantHistogramsResetMutex.Unlock()
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"os"
+	"sync"
+	"time"
+)
+
+// the histograms record microseconds, while the interval log max values are reported in milliseconds
+const hdrIntervalLogMaxValueUnitRatio = 1000.0
+
+// encodeHistogram returns the base64 compressed ( V2 ) snapshot of the histogram, which can be decoded via hdrhistogram.Decode
+func encodeHistogram(histogram *hdrhistogram.Histogram) string {
+	encoded, err := histogram.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// GetEncodedHistogramsMap returns the encoded snapshot of each query histogram, plus the "Total" one
+func GetEncodedHistogramsMap(cmds []string, perQueryHistograms []*hdrhistogram.Histogram, totalsHistogram *hdrhistogram.Histogram) map[string]string {
+	encodedMap := map[string]string{}
+	for i, query := range cmds {
+		encodedMap[query] = encodeHistogram(perQueryHistograms[i])
+	}
+	encodedMap["Total"] = encodeHistogram(totalsHistogram)
+	return encodedMap
+}
+
+// intervalHistogramsOutput receives the client ( "client" tag ) and RedisGraph internal ( "graph-internal" tag )
+// latency histograms of each reporting period
+type intervalHistogramsOutput interface {
+	outputInterval(tag string, histogram *hdrhistogram.Histogram, intervalStart time.Time, intervalEnd time.Time) error
+}
+
+// hdrIntervalLog writes one interval histogram per reporting tick, in the HdrHistogram log format,
+// so that it can be processed by the standard HdrHistogram tooling ( e.g. HistogramLogProcessor ).
+// Interval timestamps are relative to the log start time, which is kept across all the runs of the benchmark
+type hdrIntervalLog struct {
+	file      *os.File
+	writer    *hdrhistogram.HistogramLogWriter
+	startTime time.Time
+	mutex     sync.Mutex
+}
+
+func newHdrIntervalLog(fileName string, startTime time.Time) (l *hdrIntervalLog, err error) {
+	var file *os.File
+	file, err = os.Create(fileName)
+	if err != nil {
+		return
+	}
+	l = &hdrIntervalLog{file: file, writer: hdrhistogram.NewHistogramLogWriter(file), startTime: startTime}
+	if err = l.writer.OutputLogFormatVersion(); err != nil {
+		return
+	}
+	if err = l.writer.OutputComment("[Logged with redisgraph-benchmark-go. Values are in microseconds]"); err != nil {
+		return
+	}
+	// the StartTime and BaseTime lines are written directly given the log writer ones
+	// do not follow the format expected by the HdrHistogram log readers
+	startTimeSecs := float64(startTime.UnixNano()) / 1e9
+	if _, err = fmt.Fprintf(file, "#[StartTime: %.3f (seconds since epoch), %s]\n", startTimeSecs, startTime.UTC().Format(time.RFC3339)); err != nil {
+		return
+	}
+	if _, err = fmt.Fprintf(file, "#[BaseTime: %.3f (seconds since epoch)]\n", startTimeSecs); err != nil {
+		return
+	}
+	err = l.writer.OutputLegend()
+	return
+}
+
+// outputInterval writes the histogram of the [intervalStart, intervalEnd] interval with the given tag
+func (l *hdrIntervalLog) outputInterval(tag string, histogram *hdrhistogram.Histogram, intervalStart time.Time, intervalEnd time.Time) (err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	_, err = fmt.Fprintf(l.file, "Tag=%s,%.3f,%.3f,%.3f,%s\n", tag, intervalStart.Sub(l.startTime).Seconds(), intervalEnd.Sub(intervalStart).Seconds(), float64(histogram.Max())/hdrIntervalLogMaxValueUnitRatio, encodeHistogram(histogram))
+	return
+}
+
+func (l *hdrIntervalLog) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_encodeHistogram(t *testing.T) {
+	histogram := hdrhistogram.New(1, 90000000000, 4)
+	for _, v := range []int64{100, 250, 1000, 5000, 120000} {
+		histogram.RecordValue(v)
+	}
+	decoded, err := hdrhistogram.Decode([]byte(encodeHistogram(histogram)))
+	if err != nil {
+		t.Fatalf("hdrhistogram.Decode() error = %v", err)
+	}
+	if decoded.TotalCount() != histogram.TotalCount() {
+		t.Errorf("decoded TotalCount() = %v, want %v", decoded.TotalCount(), histogram.TotalCount())
+	}
+	for _, q := range []float64{0, 50, 99, 100} {
+		if decoded.ValueAtQuantile(q) != histogram.ValueAtQuantile(q) {
+			t.Errorf("decoded ValueAtQuantile(%v) = %v, want %v", q, decoded.ValueAtQuantile(q), histogram.ValueAtQuantile(q))
+		}
+	}
+}
+
+func Test_hdrIntervalLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "test.hlog")
+	startTime := time.Unix(1600000000, 0)
+	l, err := newHdrIntervalLog(fileName, startTime)
+	if err != nil {
+		t.Fatalf("newHdrIntervalLog() error = %v", err)
+	}
+	histogram := hdrhistogram.New(1, 90000000000, 4)
+	histogram.RecordValue(2500)
+	if err = l.outputInterval("client", histogram, startTime.Add(time.Second), startTime.Add(6*time.Second)); err != nil {
+		t.Fatalf("outputInterval() error = %v", err)
+	}
+	l.Close()
+	content, _ := ioutil.ReadFile(fileName)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if lines[2] != "#[StartTime: 1600000000.000 (seconds since epoch), 2020-09-13T12:26:40Z]" {
+		t.Errorf("unexpected StartTime line %s", lines[2])
+	}
+	interval := lines[len(lines)-1]
+	wantPrefix := "Tag=client,1.000,5.000,2.500,"
+	if !strings.HasPrefix(interval, wantPrefix) {
+		t.Fatalf("interval line = %s, want prefix %s", interval, wantPrefix)
+	}
+	decoded, err := hdrhistogram.Decode([]byte(strings.TrimPrefix(interval, wantPrefix)))
+	if err != nil || decoded.TotalCount() != 1 {
+		t.Errorf("unable to decode the interval histogram. Error: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"log"
+	"sort"
+	"time"
+)
+
+// mergeEncodedHistograms decodes and merges the per query histograms of each result.
+// It returns the sorted list of queries ( excluding "Total" ), the per query merged histograms and the merged "Total" one
+func mergeEncodedHistograms(encodedMaps []map[string]string) (queries []string, perQuery []*hdrhistogram.Histogram, total *hdrhistogram.Histogram, err error) {
+	merged := map[string]*hdrhistogram.Histogram{}
+	for _, encodedMap := range encodedMaps {
+		for query, encoded := range encodedMap {
+			var histogram *hdrhistogram.Histogram
+			histogram, err = hdrhistogram.Decode([]byte(encoded))
+			if err != nil {
+				err = fmt.Errorf("unable to decode the histogram of query '%s': %v", query, err)
+				return
+			}
+			if _, found := merged[query]; !found {
+				merged[query] = hdrhistogram.New(1, 90000000000, 4)
+			}
+			merged[query].Merge(histogram)
+		}
+	}
+	total, found := merged["Total"]
+	if !found {
+		total = hdrhistogram.New(1, 90000000000, 4)
+	}
+	delete(merged, "Total")
+	queries = make([]string, 0, len(merged))
+	for query := range merged {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	perQuery = make([]*hdrhistogram.Histogram, len(queries))
+	for i, query := range queries {
+		perQuery[i] = merged[query]
+	}
+	return
+}
+
+// mergeTotals sums the counters of each query Totals entry
+func mergeTotals(totalsMaps []map[string]interface{}) map[string]interface{} {
+	merged := map[string]map[string]uint64{}
+	for _, totals := range totalsMaps {
+		for query, queryTotals := range totals {
+			if _, found := merged[query]; !found {
+				merged[query] = map[string]uint64{}
+			}
+			for metric, value := range toFloat64Map(queryTotals) {
+				merged[query][metric] += uint64(value)
+			}
+		}
+	}
+	totalsMap := map[string]interface{}{}
+	for query, queryTotals := range merged {
+		totalsMap[query] = queryTotals
+	}
+	return totalsMap
+}
+
+// mergeTestResults combines the results of several benchmark processes into a single one.
+// Histograms and counters are merged, and the time window spans from the earliest start to the latest end.
+// Per endpoint stats are not merged given the results do not include their histograms
+func mergeTestResults(results []*TestResult) (merged *TestResult, err error) {
+	if len(results) == 0 {
+		err = fmt.Errorf("no results to merge")
+		return
+	}
+	first := results[0]
+	merged = NewTestResult(first.Metadata, 0, 0, 0, first.TestDescription)
+	merged.RandomSeed = first.RandomSeed
+	merged.Pipeline = first.Pipeline
+	merged.DBSpecificConfigs = first.DBSpecificConfigs
+	merged.BenchmarkConfiguredDurationMillis = first.BenchmarkConfiguredDurationMillis
+	merged.StartTime = first.StartTime
+	merged.MeasurementStartTime = first.MeasurementStartTime
+	merged.EndTime = first.EndTime
+	merged.BenchmarkFullyRun = true
+	clientEncoded := make([]map[string]string, 0, len(results))
+	graphInternalEncoded := make([]map[string]string, 0, len(results))
+	totals := make([]map[string]interface{}, 0, len(results))
+	for i, result := range results {
+		if result.ResultFormatVersion != resultFormatVersion {
+			err = fmt.Errorf("result %d has the result format version '%s', while the current one is '%s'", i+1, result.ResultFormatVersion, resultFormatVersion)
+			return
+		}
+		if len(result.EncodedClientHistograms) == 0 {
+			err = fmt.Errorf("result %d has no histogram data", i+1)
+			return
+		}
+		merged.Clients += result.Clients
+		merged.MaxRps += result.MaxRps
+		merged.BenchmarkConfiguredCommandsLimit += result.BenchmarkConfiguredCommandsLimit
+		merged.IssuedCommands += result.IssuedCommands
+		merged.BenchmarkFullyRun = merged.BenchmarkFullyRun && result.BenchmarkFullyRun
+		if result.StartTime < merged.StartTime {
+			merged.StartTime = result.StartTime
+		}
+		if result.MeasurementStartTime < merged.MeasurementStartTime {
+			merged.MeasurementStartTime = result.MeasurementStartTime
+		}
+		if result.EndTime > merged.EndTime {
+			merged.EndTime = result.EndTime
+		}
+		merged.SLOs = append(merged.SLOs, result.SLOs...)
+		merged.FailoverEvents = append(merged.FailoverEvents, result.FailoverEvents...)
+		clientEncoded = append(clientEncoded, result.EncodedClientHistograms)
+		graphInternalEncoded = append(graphInternalEncoded, result.EncodedGraphInternalHistograms)
+		totals = append(totals, result.Totals)
+	}
+	merged.DurationMillis = merged.EndTime - merged.MeasurementStartTime
+	duration := time.Duration(merged.DurationMillis) * time.Millisecond
+
+	queries, clientPerQuery, clientTotal, err := mergeEncodedHistograms(clientEncoded)
+	if err != nil {
+		return
+	}
+	graphInternalQueries, graphInternalPerQuery, graphInternalTotal, err := mergeEncodedHistograms(graphInternalEncoded)
+	if err != nil {
+		return
+	}
+	overallClientLatencies, clientLatencyMap := GetOverallLatencies(queries, clientPerQuery, clientTotal)
+	overallGraphInternalLatencies, internalLatencyMap := GetOverallLatencies(graphInternalQueries, graphInternalPerQuery, graphInternalTotal)
+	merged.OverallClientLatencies = overallClientLatencies
+	merged.OverallGraphInternalLatencies = overallGraphInternalLatencies
+	merged.RelativeInternalExternalLatencyDiff, merged.AbsoluteInternalExternalLatencyDiff = GenerateInternalExternalRatioLatencies(internalLatencyMap, clientLatencyMap)
+	merged.OverallQueryRates = GetOverallRatesMap(duration, queries, clientPerQuery, clientTotal)
+	merged.EncodedClientHistograms = GetEncodedHistogramsMap(queries, clientPerQuery, clientTotal)
+	merged.EncodedGraphInternalHistograms = GetEncodedHistogramsMap(graphInternalQueries, graphInternalPerQuery, graphInternalTotal)
+	merged.Totals = mergeTotals(totals)
+	return
+}
+
+// runMerge implements the merge subcommand, returning the process exit code
+func runMerge(args []string) int {
+	mergeFlags := flag.NewFlagSet("merge", flag.ExitOnError)
+	jsonOutputFile := mergeFlags.String("json-out-file", "merged-benchmark-results.json", "Name of json output file to output the merged benchmark results.")
+	mergeFlags.Usage = func() {
+		fmt.Fprintf(mergeFlags.Output(), "Usage of merge: redisgraph-benchmark-go merge [options] <results.json> <results.json> [<results.json> ...]\n")
+		mergeFlags.PrintDefaults()
+	}
+	mergeFlags.Parse(args)
+	files := mergeFlags.Args()
+	if len(files) < 2 {
+		mergeFlags.Usage()
+		log.Fatalf("You need to specify at least two results files to merge.")
+	}
+	results := make([]*TestResult, len(files))
+	for i, file := range files {
+		var err error
+		results[i], err = loadTestResult(file)
+		if err != nil {
+			log.Fatalf("Unable to load results file %s. Error: %v", file, err)
+		}
+	}
+	merged, err := mergeTestResults(results)
+	if err != nil {
+		log.Fatalf("Unable to merge the results files. Error: %v", err)
+	}
+	log.Printf("Merged %d results files: %d commands issued by %d clients in %.3f seconds ( %.0f requests per second )\n", len(files), merged.IssuedCommands, merged.Clients, float64(merged.DurationMillis)/1000.0, merged.OverallQueryRates["Total"])
+	saveJsonResult(merged, jsonOutputFile)
+	return 0
+}
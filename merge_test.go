@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"testing"
+)
+
+func newTestResultWithHistograms(startTime, endTime int64, values map[string][]int64, issuedQueries map[string]uint64) *TestResult {
+	result := NewTestResult("", 10, 0, 0, "")
+	result.StartTime = startTime
+	result.MeasurementStartTime = startTime
+	result.EndTime = endTime
+	result.BenchmarkFullyRun = true
+	result.EncodedClientHistograms = map[string]string{}
+	result.EncodedGraphInternalHistograms = map[string]string{}
+	result.Totals = map[string]interface{}{}
+	total := hdrhistogram.New(1, 90000000000, 4)
+	for query, queryValues := range values {
+		histogram := hdrhistogram.New(1, 90000000000, 4)
+		for _, v := range queryValues {
+			histogram.RecordValue(v)
+			total.RecordValue(v)
+			result.IssuedCommands++
+		}
+		result.EncodedClientHistograms[query] = encodeHistogram(histogram)
+		result.EncodedGraphInternalHistograms[query] = encodeHistogram(histogram)
+		result.Totals[query] = map[string]interface{}{"IssuedQueries": float64(issuedQueries[query]), "Errors": 1.0}
+	}
+	result.EncodedClientHistograms["Total"] = encodeHistogram(total)
+	result.EncodedGraphInternalHistograms["Total"] = encodeHistogram(total)
+	return result
+}
+
+func Test_mergeTestResults(t *testing.T) {
+	r1 := newTestResultWithHistograms(1000, 3000, map[string][]int64{"CREATE (n)": {1000, 1000, 1000}}, map[string]uint64{"CREATE (n)": 3})
+	r2 := newTestResultWithHistograms(2000, 5000, map[string][]int64{"CREATE (n)": {3000}, "MATCH (n) RETURN n": {5000, 5000, 5000, 5000}}, map[string]uint64{"CREATE (n)": 1, "MATCH (n) RETURN n": 4})
+	merged, err := mergeTestResults([]*TestResult{r1, r2})
+	if err != nil {
+		t.Fatalf("mergeTestResults() error = %v", err)
+	}
+	if merged.Clients != 20 || merged.IssuedCommands != 8 {
+		t.Errorf("mergeTestResults() Clients = %d IssuedCommands = %d, want 20 and 8", merged.Clients, merged.IssuedCommands)
+	}
+	if merged.StartTime != 1000 || merged.EndTime != 5000 || merged.DurationMillis != 4000 {
+		t.Errorf("mergeTestResults() window = [%d, %d] ( %d ms ), want [1000, 5000] ( 4000 ms )", merged.StartTime, merged.EndTime, merged.DurationMillis)
+	}
+	if got := merged.OverallQueryRates["Total"].(float64); got != 2.0 {
+		t.Errorf("mergeTestResults() Total rate = %v, want 2", got)
+	}
+	// the merged p50 needs to come from the merged histogram rather than from averaging quantiles
+	if got := merged.OverallClientLatencies["Total"].(map[string]float64)["q50"]; got != 3.0 {
+		t.Errorf("mergeTestResults() Total q50 = %v, want 3", got)
+	}
+	if got := merged.OverallClientLatencies["CREATE (n)"].(map[string]float64)["q100"]; got != 3.0 {
+		t.Errorf("mergeTestResults() CREATE (n) q100 = %v, want 3", got)
+	}
+	if got := merged.Totals["CREATE (n)"].(map[string]uint64); got["IssuedQueries"] != 4 || got["Errors"] != 2 {
+		t.Errorf("mergeTestResults() CREATE (n) totals = %v, want 4 issued queries and 2 errors", got)
+	}
+}
+
+func Test_mergeTestResults_withoutHistograms(t *testing.T) {
+	if _, err := mergeTestResults([]*TestResult{NewTestResult("", 1, 0, 0, "")}); err == nil {
+		t.Errorf("mergeTestResults() expected an error for results without histogram data")
+	}
+}
+
+func Test_mergeTestResults_otherFormatVersion(t *testing.T) {
+	other := newTestResultWithHistograms(1000, 3000, map[string][]int64{"CREATE (n)": {1000}}, map[string]uint64{"CREATE (n)": 1})
+	other.ResultFormatVersion = "0.0.0"
+	current := newTestResultWithHistograms(1000, 3000, map[string][]int64{"CREATE (n)": {1000}}, map[string]uint64{"CREATE (n)": 1})
+	if _, err := mergeTestResults([]*TestResult{current, other}); err == nil {
+		t.Errorf("mergeTestResults() expected an error for results with another format version")
+	}
+}
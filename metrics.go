@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const metricsPrefix = "redisgraph_benchmark_"
+
+// the queries of the current run, set along with the globals. Protected by instantHistogramsResetMutex
+var metricsQueries []string
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsCounter is a per query counter exposed on the metrics endpoint
+type metricsCounter struct {
+	name     string
+	help     string
+	perQuery []uint64
+}
+
+// startMetricsServer exposes the counters and latencies of the current run on the /metrics endpoint, in the Prometheus text format.
+// Counters are reset on each run ( e.g. on each scenario phase or sweep combination ).
+// The listener is bound before returning, so that an unavailable address is reported before the benchmark starts
+func startMetricsServer(listen string) (err error) {
+	var listener net.Listener
+	listener, err = net.Listen("tcp", listen)
+	if err != nil {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	log.Printf("Exposing the Prometheus metrics on %s/metrics\n", listener.Addr())
+	go func() {
+		if serveErr := http.Serve(listener, mux); serveErr != nil {
+			log.Printf("Metrics endpoint stopped. Continuing the benchmark without it. Error: %v\n", serveErr)
+		}
+	}()
+	return
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	instantHistogramsResetMutex.Lock()
+	defer instantHistogramsResetMutex.Unlock()
+	writeMetrics(w, metricsQueries)
+}
+
+// writeMetrics writes the metrics of the given queries. The caller needs to hold instantHistogramsResetMutex
+func writeMetrics(w io.Writer, queries []string) {
+	writeMetricHeader(w, "commands_total", "Number of issued commands.", "counter")
+	fmt.Fprintf(w, "%scommands_total %d\n", metricsPrefix, atomic.LoadUint64(&totalCommands))
+	writeMetricHeader(w, "errors_total", "Number of error replies.", "counter")
+	fmt.Fprintf(w, "%serrors_total %d\n", metricsPrefix, atomic.LoadUint64(&totalErrors))
+	writeMetricHeader(w, "empty_resultsets_total", "Number of empty resultsets.", "counter")
+	fmt.Fprintf(w, "%sempty_resultsets_total %d\n", metricsPrefix, totalEmptyResultsets)
+	if clientSide_AllQueries_OverallLatencies != nil {
+		writeLatencySummary(w, "client_latency_seconds", "Client latency, including the round trip time, across all queries.", []string{""}, []*hdrhistogram.Histogram{clientSide_AllQueries_OverallLatencies})
+		writeLatencySummary(w, "graph_internal_latency_seconds", "RedisGraph internal execution time across all queries.", []string{""}, []*hdrhistogram.Histogram{serverSide_AllQueries_GraphInternalTime_OverallLatencies})
+	}
+	// the globals are recreated on each run. skip the per query metrics if they do not match the queries yet
+	if len(queries) == 0 || len(clientSide_PerQuery_OverallLatencies) != len(queries) || len(errorsPerQuery) != len(queries) {
+		return
+	}
+	labels := make([]string, len(queries))
+	commandsPerQuery := make([]uint64, len(queries))
+	for i, query := range queries {
+		labels[i] = fmt.Sprintf(`query="%s"`, labelValueReplacer.Replace(query))
+		commandsPerQuery[i] = uint64(clientSide_PerQuery_OverallLatencies[i].TotalCount())
+	}
+	counters := []metricsCounter{
+		{"query_commands_total", "Number of issued commands per query.", commandsPerQuery},
+		{"query_errors_total", "Number of error replies per query.", errorsPerQuery},
+		{"query_nodes_created_total", "Number of nodes created per query.", totalNodesCreatedPerQuery},
+		{"query_nodes_deleted_total", "Number of nodes deleted per query.", totalNodesDeletedPerQuery},
+		{"query_labels_added_total", "Number of labels added per query.", totalLabelsAddedPerQuery},
+		{"query_properties_set_total", "Number of properties set per query.", totalPropertiesSetPerQuery},
+		{"query_relationships_created_total", "Number of relationships created per query.", totalRelationshipsCreatedPerQuery},
+		{"query_relationships_deleted_total", "Number of relationships deleted per query.", totalRelationshipsDeletedPerQuery},
+	}
+	for _, counter := range counters {
+		writeMetricHeader(w, counter.name, counter.help, "counter")
+		for i := range queries {
+			fmt.Fprintf(w, "%s%s{%s} %d\n", metricsPrefix, counter.name, labels[i], counter.perQuery[i])
+		}
+	}
+	writeLatencySummary(w, "query_client_latency_seconds", "Client latency per query, including the round trip time.", labels, clientSide_PerQuery_OverallLatencies)
+	writeLatencySummary(w, "query_graph_internal_latency_seconds", "RedisGraph internal execution time per query.", labels, serverSide_PerQuery_GraphInternalTime_OverallLatencies)
+}
+
+func writeMetricHeader(w io.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s%s %s\n", metricsPrefix, name, help)
+	fmt.Fprintf(w, "# TYPE %s%s %s\n", metricsPrefix, name, metricType)
+}
+
+// writeLatencySummary writes one summary per histogram, with the configured percentiles as quantiles.
+// Histograms are recorded in microseconds and exposed in seconds
+func writeLatencySummary(w io.Writer, name, help string, labels []string, histograms []*hdrhistogram.Histogram) {
+	writeMetricHeader(w, name, help, "summary")
+	for i, histogram := range histograms {
+		separator := ""
+		if labels[i] != "" {
+			separator = ","
+		}
+		for _, percentile := range reportedPercentiles {
+			value := float64(histogram.ValueAtQuantile(percentile)) / 1e6
+			fmt.Fprintf(w, "%s%s{%s%squantile=\"%s\"} %s\n", metricsPrefix, name, labels[i], separator, strconv.FormatFloat(percentile/100.0, 'g', 6, 64), formatMetricValue(value))
+		}
+		sum := histogram.Mean() * float64(histogram.TotalCount()) / 1e6
+		if labels[i] != "" {
+			fmt.Fprintf(w, "%s%s_sum{%s} %s\n", metricsPrefix, name, labels[i], formatMetricValue(sum))
+			fmt.Fprintf(w, "%s%s_count{%s} %d\n", metricsPrefix, name, labels[i], histogram.TotalCount())
+		} else {
+			fmt.Fprintf(w, "%s%s_sum %s\n", metricsPrefix, name, formatMetricValue(sum))
+			fmt.Fprintf(w, "%s%s_count %d\n", metricsPrefix, name, histogram.TotalCount())
+		}
+	}
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
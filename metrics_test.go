@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_handleMetrics(t *testing.T) {
+	defer func(previous []float64) { reportedPercentiles = previous }(reportedPercentiles)
+	reportedPercentiles = []float64{50, 99.9}
+	queries := []string{"CREATE (n)", `MATCH (n {name:"a"}) RETURN n`}
+	createRequiredGlobalStructs(len(queries), 1)
+	metricsQueries = queries
+	defer func() { metricsQueries = nil }()
+	for _, v := range []int64{1000, 2000, 3000} {
+		clientSide_PerQuery_OverallLatencies[0].RecordValue(v)
+		clientSide_AllQueries_OverallLatencies.RecordValue(v)
+	}
+	totalCommands = 3
+	errorsPerQuery[1] = 2
+	totalNodesCreatedPerQuery[0] = 3
+
+	recorder := httptest.NewRecorder()
+	handleMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Result().Body)
+	got := string(body)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"commands", "redisgraph_benchmark_commands_total 3\n"},
+		{"counter type", "# TYPE redisgraph_benchmark_query_errors_total counter\n"},
+		{"escaped label", `redisgraph_benchmark_query_errors_total{query="MATCH (n {name:\"a\"}) RETURN n"} 2` + "\n"},
+		{"nodes created", `redisgraph_benchmark_query_nodes_created_total{query="CREATE (n)"} 3` + "\n"},
+		{"overall quantile", `redisgraph_benchmark_client_latency_seconds{quantile="0.5"} 0.002` + "\n"},
+		{"overall count", "redisgraph_benchmark_client_latency_seconds_count 3\n"},
+		{"per query quantile", `redisgraph_benchmark_query_client_latency_seconds{query="CREATE (n)",quantile="0.999"} 0.003` + "\n"},
+		{"per query sum", `redisgraph_benchmark_query_client_latency_seconds_sum{query="CREATE (n)"} 0.006` + "\n"},
+		{"summary type", "# TYPE redisgraph_benchmark_query_graph_internal_latency_seconds summary\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("handleMetrics() output does not contain %q. Got:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test_handleMetrics_whileProcessing scrapes the endpoint while the datapoints are being processed. Run with -race
+func Test_handleMetrics_whileProcessing(t *testing.T) {
+	queries := []string{"CREATE (n)", "MATCH (n) RETURN n"}
+	instantHistogramsResetMutex.Lock()
+	createRequiredGlobalStructs(len(queries), 1)
+	metricsQueries = queries
+	instantHistogramsResetMutex.Unlock()
+	defer func() { metricsQueries = nil }()
+	graphStatsChann := make(chan GraphQueryDatapoint, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go processGraphDatapointsChannel(graphStatsChann, make(chan GraphQueryDatapoint), make(chan os.Signal), &wg, &instantHistogramsResetMutex)
+	// keep scraping up until all the datapoints are processed
+	processed := make(chan struct{})
+	scrapes := sync.WaitGroup{}
+	scrapes.Add(1)
+	go func() {
+		defer scrapes.Done()
+		for {
+			select {
+			case <-processed:
+				return
+			default:
+				handleMetrics(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+			}
+		}
+	}()
+	for i := 0; i < 500; i++ {
+		graphStatsChann <- GraphQueryDatapoint{CmdPos: i % 2, ClientDurationMicros: 1000, Error: i%10 == 0, NodesCreated: 1, Empty: true}
+	}
+	close(graphStatsChann)
+	wg.Wait()
+	close(processed)
+	scrapes.Wait()
+	recorder := httptest.NewRecorder()
+	handleMetrics(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Result().Body)
+	if want := `redisgraph_benchmark_query_errors_total{query="CREATE (n)"} 50` + "\n"; !strings.Contains(string(body), want) {
+		t.Errorf("handleMetrics() output does not contain %q. Got:\n%s", want, body)
+	}
+}
+
+func Test_startMetricsServer_addressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	defer listener.Close()
+	if err := startMetricsServer(listener.Addr().String()); err == nil {
+		t.Errorf("startMetricsServer() expected an error for an address already in use")
+	}
+}
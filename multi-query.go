@@ -19,33 +19,40 @@ func sample(cdf []float32) int {
 	return bucket
 }
 
-func prepareCommandsDistribution(queries arrayStringParameters, cmds []string, cmdRates []float64) (int, []float32) {
+func prepareCommandsDistribution(queries arrayStringParameters, queryRates arrayStringParameters, cmds []string, cmdRates []float64) (int, []float32) {
 	var totalDifferentCommands = len(cmds)
-	var totalRateSum = 0.0
 	var err error
 	for i, rawCmdString := range queries {
 		cmds[i] = rawCmdString
-		if i >= len(benchmarkQueryRates) {
+		if i >= len(queryRates) {
 			cmdRates[i] = 1
 
 		} else {
-			cmdRates[i], err = strconv.ParseFloat(benchmarkQueryRates[i], 64)
+			cmdRates[i], err = strconv.ParseFloat(queryRates[i], 64)
 			if err != nil {
-				log.Fatalf("Error while converting query-rate param %s: %v", benchmarkQueryRates[i], err)
+				log.Fatalf("Error while converting query-rate param %s: %v", queryRates[i], err)
 			}
 		}
+	}
+	if len(queryRates) > 0 && (len(queryRates) != len(queries)) {
+		log.Fatalf("When specifiying -query-rate parameter, you need to have the same number of -query/-query-ro and -query-rate parameters. Number of time -query/-query-ro ( %d ) != Number of times -query-params ( %d )", len(queries), len(queryRates))
+	}
+	return totalDifferentCommands, getCommandsCDF(cmdRates)
+}
+
+// getCommandsCDF returns the cumulative distribution function of the commands, given each command rate.
+// The command rates need to sum up to 1.0
+func getCommandsCDF(cmdRates []float64) []float32 {
+	var totalRateSum = 0.0
+	for i := 0; i < len(cmdRates); i++ {
 		totalRateSum += cmdRates[i]
 	}
 	// probability density function
 	if math.Abs(1.0-totalRateSum) > 0.01 {
 		log.Fatalf("Total ratio should be 1.0 ( currently is %f )", totalRateSum)
 	}
-	// probability density function
-	if len(benchmarkQueryRates) > 0 && (len(benchmarkQueryRates) != (len(benchmarkQueries) + len(benchmarkQueriesRO))) {
-		log.Fatalf("When specifiying -query-rate parameter, you need to have the same number of -query/-query-ro and -query-rate parameters. Number of time -query ( %d ) != Number of times -query-params ( %d )", len(benchmarkQueries), (len(benchmarkQueryRates) + len(benchmarkQueriesRO)))
-	}
-	pdf := make([]float32, len(queries))
-	cdf := make([]float32, len(queries))
+	pdf := make([]float32, len(cmdRates))
+	cdf := make([]float32, len(cmdRates))
 	for i := 0; i < len(cmdRates); i++ {
 		pdf[i] = float32(cmdRates[i])
 		cdf[i] = 0
@@ -55,5 +62,5 @@ func prepareCommandsDistribution(queries arrayStringParameters, cmds []string, c
 	for i := 1; i < len(cmdRates); i++ {
 		cdf[i] = cdf[i-1] + pdf[i]
 	}
-	return totalDifferentCommands, cdf
+	return cdf
 }
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	outputFormatTable    = "table"
+	outputFormatCsv      = "csv"
+	outputFormatMarkdown = "markdown"
+	outputFormatHtml     = "html"
+)
+
+// outputFormat of the final summary. Set via the -output-format parameter
+var outputFormat = outputFormatTable
+
+// jsonLinesOnStdout is set when the jsonl exporter streams to stdout
+var jsonLinesOnStdout = false
+
+// getProgressWriter returns where the live progress lines are printed. Unless the final summary is a text table
+// they go to stderr, so that redirecting stdout to a file only keeps the final summary. The same applies when
+// the jsonl exporter streams to stdout
+func getProgressWriter() io.Writer {
+	if outputFormat == outputFormatTable && !jsonLinesOnStdout {
+		return os.Stdout
+	}
+	return os.Stderr
+}
+
+func isValidOutputFormat(format string) bool {
+	switch format {
+	case outputFormatTable, outputFormatCsv, outputFormatMarkdown, outputFormatHtml:
+		return true
+	}
+	return false
+}
+
+// renderRuntimeStats outputs the runtime stats lines preceding the summary tables
+func renderRuntimeStats(writer io.Writer, lines []string) {
+	switch outputFormat {
+	case outputFormatHtml:
+		fmt.Fprintf(writer, "<h2>Runtime stats</h2>\n<ul>\n")
+		for _, line := range lines {
+			fmt.Fprintf(writer, "<li>%s</li>\n", html.EscapeString(line))
+		}
+		fmt.Fprintf(writer, "</ul>\n")
+	case outputFormatMarkdown:
+		fmt.Fprintf(writer, "## Runtime stats\n")
+		for _, line := range lines {
+			fmt.Fprintf(writer, "- %s\n", line)
+		}
+	default:
+		fmt.Fprintf(writer, "################# RUNTIME STATS #################\n")
+		for _, line := range lines {
+			fmt.Fprintf(writer, "%s\n", line)
+		}
+	}
+}
+
+// renderSummaryTable outputs a titled table on the configured output format. Titles are in the "## <title>\n" form
+func renderSummaryTable(writer io.Writer, tableTitle string, header []string, data [][]string) {
+	switch outputFormat {
+	case outputFormatHtml:
+		renderHtmlTable(writer, strings.TrimSpace(strings.TrimPrefix(tableTitle, "## ")), header, data)
+	case outputFormatCsv:
+		fmt.Fprintf(writer, "\n%s\n", strings.TrimSpace(tableTitle))
+		w := csv.NewWriter(writer)
+		w.Write(header)
+		w.WriteAll(data)
+	case outputFormatMarkdown:
+		fmt.Fprintf(writer, "\n%s\n", strings.TrimSpace(tableTitle))
+		table := tablewriter.NewWriter(writer)
+		table.SetHeader(header)
+		// keep the headers and cells as they are, so that the table renders on any markdown viewer
+		table.SetAutoFormatHeaders(false)
+		table.SetAutoWrapText(false)
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		for _, row := range data {
+			// queries might include pipes, e.g. on relationship types [:A|B]
+			escaped := make([]string, len(row))
+			for j, cell := range row {
+				escaped[j] = strings.Replace(cell, "|", `\|`, -1)
+			}
+			table.Append(escaped)
+		}
+		table.Render()
+	default:
+		fmt.Fprintf(writer, tableTitle)
+		table := tablewriter.NewWriter(writer)
+		table.SetHeader(header)
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		table.AppendBulk(data)
+		table.Render()
+	}
+}
+
+func renderHtmlTable(writer io.Writer, title string, header []string, data [][]string) {
+	fmt.Fprintf(writer, "<h2>%s</h2>\n<table>\n<thead>\n<tr>", html.EscapeString(title))
+	for _, column := range header {
+		fmt.Fprintf(writer, "<th>%s</th>", html.EscapeString(strings.TrimSpace(column)))
+	}
+	fmt.Fprintf(writer, "</tr>\n</thead>\n<tbody>\n")
+	for _, row := range data {
+		fmt.Fprintf(writer, "<tr>")
+		for _, cell := range row {
+			fmt.Fprintf(writer, "<td>%s</td>", html.EscapeString(cell))
+		}
+		fmt.Fprintf(writer, "</tr>\n")
+	}
+	fmt.Fprintf(writer, "</tbody>\n</table>\n")
+}
+
+// getSummaryCsvHeader returns the header of the csv final summary, with all the metrics of a query on a single row
+func getSummaryCsvHeader() []string {
+	header := []string{"Query", "Ops/sec", "Total Calls", "Total Errors"}
+	header = append(header, getLatencyHeader("")...)
+	header = append(header, getLatencyHeader("Internal ")...)
+	return append(header, "Nodes created", "Nodes deleted", "Labels added", "Properties set", "Relationships created", "Relationships deleted")
+}
+
+// renderSummaryCsv outputs the final summary as csv, one row per query plus the "Total" row
+func renderSummaryCsv(writer io.Writer, queries []string, duration time.Duration) error {
+	w := csv.NewWriter(writer)
+	if err := w.Write(getSummaryCsvHeader()); err != nil {
+		return err
+	}
+	for i := 0; i <= len(queries); i++ {
+		query := "Total"
+		clientHistogram := clientSide_AllQueries_OverallLatencies
+		internalHistogram := serverSide_AllQueries_GraphInternalTime_OverallLatencies
+		errors := totalErrors
+		counters := []uint64{totalNodesCreated, totalNodesDeleted, totalLabelsAdded, totalPropertiesSet, totalRelationshipsCreated, totalRelationshipsDeleted}
+		if i < len(queries) {
+			query = queries[i]
+			clientHistogram = clientSide_PerQuery_OverallLatencies[i]
+			internalHistogram = serverSide_PerQuery_GraphInternalTime_OverallLatencies[i]
+			errors = errorsPerQuery[i]
+			counters = []uint64{totalNodesCreatedPerQuery[i], totalNodesDeletedPerQuery[i], totalLabelsAddedPerQuery[i], totalPropertiesSetPerQuery[i], totalRelationshipsCreatedPerQuery[i], totalRelationshipsDeletedPerQuery[i]}
+		}
+		row := []string{query, fmt.Sprintf("%.f", float64(clientHistogram.TotalCount())/duration.Seconds()), fmt.Sprintf("%d", clientHistogram.TotalCount()), fmt.Sprintf("%d", errors)}
+		row = append(row, getLatencyColumns(clientHistogram)...)
+		row = append(row, getLatencyColumns(internalHistogram)...)
+		for _, counter := range counters {
+			row = append(row, fmt.Sprintf("%d", counter))
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_renderSummaryTable(t *testing.T) {
+	defer func(previous string) { outputFormat = previous }(outputFormat)
+	header := []string{"Query", "p50 latency(ms)"}
+	data := [][]string{{"MATCH (n) WHERE n.v < 5 RETURN n", "1.000"}, {"MATCH ()-[:A|B]->() RETURN 1", "2.000"}, {"Total", "1.000"}}
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{outputFormatTable, []string{"## Title\n", "P50 LATENCY(MS)"}},
+		{outputFormatMarkdown, []string{"## Title\n", "| p50 latency(ms) |", "|---", `[:A\|B]`}},
+		{outputFormatHtml, []string{"<h2>Title</h2>", "<th>p50 latency(ms)</th>", "<td>MATCH (n) WHERE n.v &lt; 5 RETURN n</td>"}},
+		{outputFormatCsv, []string{"## Title\n", "Query,p50 latency(ms)\n", "Total,1.000\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			outputFormat = tt.format
+			var buffer bytes.Buffer
+			renderSummaryTable(&buffer, "## Title\n", header, data)
+			for _, want := range tt.want {
+				if !strings.Contains(buffer.String(), want) {
+					t.Errorf("renderSummaryTable() output does not contain %q. Got:\n%s", want, buffer.String())
+				}
+			}
+		})
+	}
+}
+
+func Test_renderSummaryCsv(t *testing.T) {
+	defer func(previous []float64) { reportedPercentiles = previous }(reportedPercentiles)
+	reportedPercentiles = []float64{50, 99}
+	queries := []string{"CREATE (n)", "MATCH (n) RETURN n"}
+	createRequiredGlobalStructs(len(queries), 1)
+	for _, v := range []int64{1000, 2000} {
+		clientSide_PerQuery_OverallLatencies[0].RecordValue(v)
+		clientSide_AllQueries_OverallLatencies.RecordValue(v)
+	}
+	totalNodesCreatedPerQuery[0] = 2
+	totalNodesCreated = 2
+	var buffer bytes.Buffer
+	if err := renderSummaryCsv(&buffer, queries, time.Second); err != nil {
+		t.Fatalf("renderSummaryCsv() error = %v", err)
+	}
+	records, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatalf("renderSummaryCsv() output is not valid csv: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("renderSummaryCsv() returned %d rows, want 4", len(records))
+	}
+	header := records[0]
+	for _, row := range records[1:] {
+		if len(row) != len(header) {
+			t.Errorf("renderSummaryCsv() row %v has %d columns, want %d", row[0], len(row), len(header))
+		}
+	}
+	if records[1][0] != "CREATE (n)" || records[1][1] != "2" || records[1][2] != "2" || records[1][len(header)-6] != "2" {
+		t.Errorf("renderSummaryCsv() CREATE (n) row = %v", records[1])
+	}
+	if records[3][0] != "Total" {
+		t.Errorf("renderSummaryCsv() last row = %v, want the Total row", records[3])
+	}
+}
+
+func Test_renderFinalSummary(t *testing.T) {
+	defer func(previous string) { outputFormat = previous }(outputFormat)
+	queries := []string{"CREATE (n)"}
+	endpoints := []string{"127.0.0.1:6379", "127.0.0.1:6380"}
+	createRequiredGlobalStructs(len(queries), len(endpoints))
+	for _, v := range []int64{1000, 2000, 3000, 4000} {
+		clientSide_PerQuery_OverallLatencies[0].RecordValue(v)
+		clientSide_AllQueries_OverallLatencies.RecordValue(v)
+	}
+	totalCommands = 4
+	totalErrors = 1
+	sloResults := []SLOResult{{SLO: "p99<5", Query: "Total", Metric: "p99", Operator: "<", Threshold: 5, Value: 4, Passed: true}}
+	serverStats := []serverStatsDelta{{Metric: "used_memory", Start: 100, End: 200, Delta: 100, Max: 200}}
+	slowlog := []slowlogEntry{{1792400000, "GRAPH.QUERY", "CREATE (n)", 12.5}}
+	tests := []struct {
+		format     string
+		want       []string
+		wantAbsent []string
+	}{
+		{outputFormatTable, []string{"Total Errors 1 ( 25.000 %)", "## Per endpoint Client Latency summary table", "## Server stats summary table", "## Slowest queries table", "## SLO summary table"}, nil},
+		{outputFormatCsv, []string{"## Per endpoint Client Latency summary table\nQuery,", "127.0.0.1:6380", "## Server stats summary table\nMetric,", "used_memory", "GRAPH.QUERY", "## SLO summary table\nQuery,", "Total,p99,< 5,4.000,PASS"}, []string{"Total Errors 1 (", "## Overall"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			outputFormat = tt.format
+			var buffer bytes.Buffer
+			renderFinalSummary(&buffer, queries, endpoints, totalCommands, time.Second, sloResults, serverStats, slowlog)
+			for _, want := range tt.want {
+				if !strings.Contains(buffer.String(), want) {
+					t.Errorf("renderFinalSummary() is missing %q:\n%s", want, buffer.String())
+				}
+			}
+			// the per query csv table starts on the first line
+			if tt.format == outputFormatCsv && !strings.HasPrefix(buffer.String(), "Query,") {
+				t.Errorf("renderFinalSummary() csv does not start with the per query header:\n%s", buffer.String())
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(buffer.String(), absent) {
+					t.Errorf("renderFinalSummary() includes %q:\n%s", absent, buffer.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"strconv"
+	"strings"
+)
+
+// defaultPercentiles are the percentiles reported when -percentiles is not set. They match the historical
+// q0, q50, q95, q99, q999 and q100 keys of the json results, so that existing consumers keep working
+var defaultPercentiles = []float64{0, 50, 95, 99, 99.9, 100}
+
+// reportedPercentiles drives the latency percentiles of every table, json map and exported series.
+// Set via the -percentiles parameter
+var reportedPercentiles = defaultPercentiles
+
+// customPercentiles is set when the -percentiles parameter is used. Only then the json latency maps
+// include the min, max and stddev on top of the average and the percentiles
+var customPercentiles = false
+
+// legacyPercentileKeys are the json keys of the default percentiles which predate the underscore separated keys
+var legacyPercentileKeys = map[float64]string{99.9: "q999"}
+
+func parsePercentiles(value string) (percentiles []float64, err error) {
+	percentiles = []float64{}
+	for _, s := range strings.Split(value, ",") {
+		var p float64
+		p, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return
+		}
+		if p < 0 || p > 100 {
+			err = fmt.Errorf("percentile %v is out of the [0,100] range", p)
+			return
+		}
+		percentiles = append(percentiles, p)
+	}
+	return
+}
+
+// getPercentileKey returns the json key of the percentile, e.g. q50 for 50 or q99_99 for 99.99.
+// The decimal point is kept as an underscore, so that each percentile has its own key ( e.g. 99.99 and 9.999 ),
+// apart from the legacy q999 key of 99.9
+func getPercentileKey(percentile float64) string {
+	if key, found := legacyPercentileKeys[percentile]; found {
+		return key
+	}
+	return "q" + strings.Replace(strconv.FormatFloat(percentile, 'f', -1, 64), ".", "_", 1)
+}
+
+// getPercentileLabel returns the label of the percentile on tables and exported series, e.g. p50 or p99.9
+func getPercentileLabel(percentile float64) string {
+	return "p" + strconv.FormatFloat(percentile, 'f', -1, 64)
+}
+
+// getLatencyMapKeys returns the keys of the latency maps, in the order they are reported
+func getLatencyMapKeys() []string {
+	if !customPercentiles {
+		keys := []string{"avg"}
+		for _, percentile := range reportedPercentiles {
+			keys = append(keys, getPercentileKey(percentile))
+		}
+		return keys
+	}
+	keys := []string{"avg", "min"}
+	for _, percentile := range reportedPercentiles {
+		keys = append(keys, getPercentileKey(percentile))
+	}
+	return append(keys, "max", "stddev")
+}
+
+// getTablePercentiles returns the percentiles of the table columns. p0 and p100 are skipped given the tables
+// always have the min and max columns
+func getTablePercentiles() []float64 {
+	percentiles := make([]float64, 0, len(reportedPercentiles))
+	for _, percentile := range reportedPercentiles {
+		if percentile > 0 && percentile < 100 {
+			percentiles = append(percentiles, percentile)
+		}
+	}
+	return percentiles
+}
+
+// getLatencyHeader returns the table header of the latency columns, with the given prefix ( if any )
+func getLatencyHeader(prefix string) []string {
+	header := []string{prefix + "Avg. latency(ms)", prefix + "Min latency(ms)"}
+	for _, percentile := range getTablePercentiles() {
+		header = append(header, fmt.Sprintf("%s%s latency(ms)", prefix, getPercentileLabel(percentile)))
+	}
+	return append(header, prefix+"Max latency(ms)", prefix+"Stddev latency(ms)")
+}
+
+// getLatencyColumns returns the latency columns of the histogram, in milliseconds, matching getLatencyHeader
+func getLatencyColumns(histogram *hdrhistogram.Histogram) []string {
+	columns := []string{fmt.Sprintf("%.3f", histogram.Mean()/1000.0), fmt.Sprintf("%.3f", float64(histogram.Min())/1000.0)}
+	for _, percentile := range getTablePercentiles() {
+		columns = append(columns, fmt.Sprintf("%.3f", float64(histogram.ValueAtQuantile(percentile))/1000.0))
+	}
+	return append(columns, fmt.Sprintf("%.3f", float64(histogram.Max())/1000.0), fmt.Sprintf("%.3f", histogram.StdDev()/1000.0))
+}
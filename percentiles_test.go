@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parsePercentiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []float64
+		wantErr bool
+	}{
+		{"default", "50,95,99,99.9", []float64{50, 95, 99, 99.9}, false},
+		{"spaces", "50, 90 ,99.99", []float64{50, 90, 99.99}, false},
+		{"single", "99", []float64{99}, false},
+		{"out of range", "50,101", nil, true},
+		{"negative", "-1", nil, true},
+		{"not a number", "50,p99", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePercentiles(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePercentiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePercentiles() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getPercentileKeyAndLabel(t *testing.T) {
+	tests := []struct {
+		percentile float64
+		wantKey    string
+		wantLabel  string
+	}{
+		{50, "q50", "p50"},
+		{99.9, "q999", "p99.9"},
+		{9.99, "q9_99", "p9.99"},
+		{99.99, "q99_99", "p99.99"},
+		{0, "q0", "p0"},
+		{100, "q100", "p100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantLabel, func(t *testing.T) {
+			if got := getPercentileKey(tt.percentile); got != tt.wantKey {
+				t.Errorf("getPercentileKey() = %v, want %v", got, tt.wantKey)
+			}
+			if got := getPercentileLabel(tt.percentile); got != tt.wantLabel {
+				t.Errorf("getPercentileLabel() = %v, want %v", got, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func Test_getLatencyMapKeys(t *testing.T) {
+	// the default keys are the ones the json results always had
+	want := []string{"avg", "q0", "q50", "q95", "q99", "q999", "q100"}
+	if got := getLatencyMapKeys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("getLatencyMapKeys() = %v, want %v", got, want)
+	}
+	// p0 and p100 are reported as the min and max columns
+	if got, want := getLatencyHeader(""), 8; len(got) != want {
+		t.Errorf("getLatencyHeader() has %d columns, want %d", len(got), want)
+	}
+	defer func(previous []float64) { reportedPercentiles, customPercentiles = previous, false }(reportedPercentiles)
+	reportedPercentiles, customPercentiles = []float64{50, 90, 99.99}, true
+	want = []string{"avg", "min", "q50", "q90", "q99_99", "max", "stddev"}
+	if got := getLatencyMapKeys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("getLatencyMapKeys() = %v, want %v", got, want)
+	}
+	if got := getLatencyHeader("Internal "); len(got) != len(want) {
+		t.Errorf("getLatencyHeader() has %d columns, want %d", len(got), len(want))
+	}
+}
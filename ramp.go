@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	rampModeRps     = "rps"
+	rampModeClients = "clients"
+)
+
+// rampSettings controls the stepped load ramp. On each step the target ( rps or clients ) is increased by step,
+// up until max is reached or the step breaches the SLOs
+type rampSettings struct {
+	mode            string
+	start           uint64
+	step            uint64
+	max             uint64
+	stepDuration    time.Duration
+	sloP99Millis    float64
+	sloMaxErrorRate float64
+}
+
+type RampStepResult struct {
+	Step                   int                `json:"Step"`
+	Clients                uint64             `json:"Clients"`
+	TargetRps              int64              `json:"TargetRps"`
+	AchievedRps            float64            `json:"AchievedRps"`
+	IssuedCommands         uint64             `json:"IssuedCommands"`
+	ErrorRate              float64            `json:"ErrorRate"`
+	ClientLatencies        map[string]float64 `json:"ClientLatencies"`
+	GraphInternalLatencies map[string]float64 `json:"GraphInternalLatencies"`
+	MetSLO                 bool               `json:"MetSLO"`
+	SLOBreaches            []string           `json:"SLOBreaches"`
+	StartTime              int64              `json:"StartTime"`
+	DurationMillis         int64              `json:"DurationMillis"`
+	BenchmarkFullyRun      bool               `json:"BenchmarkFullyRun"`
+	// the -slo objectives, evaluated against the step results. Failures are accounted on the exit code
+	SLOs []SLOResult `json:"SLOs"`
+}
+
+type RampResult struct {
+	Mode            string           `json:"Mode"`
+	SLOP99Millis    float64          `json:"SLOP99Millis"`
+	SLOMaxErrorRate float64          `json:"SLOMaxErrorRate"`
+	Steps           []RampStepResult `json:"Steps"`
+	// Highest step that met the SLOs. nil if none did
+	MaxSustainableStep *RampStepResult `json:"MaxSustainableStep"`
+}
+
+// getStepTarget returns the target ( rps or clients ) of the given step, capped at max
+func (r rampSettings) getStepTarget(step int) uint64 {
+	target := r.start + uint64(step)*r.step
+	if r.max > 0 && target > r.max {
+		target = r.max
+	}
+	return target
+}
+
+// checkRampStepSLO returns the list of SLOs breached by the step. A SLO set to 0 is not checked
+func checkRampStepSLO(p99Millis float64, errorRate float64, sloP99Millis float64, sloMaxErrorRate float64) (breaches []string) {
+	breaches = []string{}
+	if sloP99Millis > 0 && p99Millis > sloP99Millis {
+		breaches = append(breaches, fmt.Sprintf("p99 %.3f ms > %.3f ms", p99Millis, sloP99Millis))
+	}
+	if sloMaxErrorRate > 0 && errorRate > sloMaxErrorRate {
+		breaches = append(breaches, fmt.Sprintf("error rate %.3f %% > %.3f %%", errorRate, sloMaxErrorRate))
+	}
+	return
+}
+
+// runRamp runs the workload in steps of fixed duration, increasing the load on each step up until the SLOs are breached
+func runRamp(runner *benchmarkRunner, r rampSettings, workload benchmarkWorkload) (rampResult *RampResult, completed bool) {
+	rampResult = &RampResult{Mode: r.mode, SLOP99Millis: r.sloP99Millis, SLOMaxErrorRate: r.sloMaxErrorRate, Steps: []RampStepResult{}}
+	completed = true
+	for step := 0; ; step++ {
+		target := r.getStepTarget(step)
+		w := workload
+		w.name = fmt.Sprintf("ramp step %d", step+1)
+		w.duration = r.stepDuration
+		w.numberRequests = 0
+		if r.mode == rampModeRps {
+			w.rps = int64(target)
+		} else {
+			w.clients = target
+		}
+		// only the first step warms up
+		if step > 0 {
+			w.warmupRequests = 0
+			w.warmupTime = 0
+		}
+		log.Printf("Running ramp step %d with %d clients and target rps %d\n", step+1, w.clients, w.rps)
+		var stepResult *TestResult
+		stepResult, completed = runner.run(w)
+		_, clientLatencies := generateLatenciesMap(clientSide_AllQueries_OverallLatencies)
+		_, graphInternalLatencies := generateLatenciesMap(serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+		errorRate := 0.0
+		if stepResult.IssuedCommands > 0 {
+			errorRate = float64(totalErrors) / float64(stepResult.IssuedCommands) * 100.0
+		}
+		rampStep := RampStepResult{
+			Step:                   step + 1,
+			Clients:                w.clients,
+			TargetRps:              w.rps,
+			AchievedRps:            float64(stepResult.IssuedCommands) / (float64(stepResult.DurationMillis) / 1000.0),
+			IssuedCommands:         stepResult.IssuedCommands,
+			ErrorRate:              errorRate,
+			ClientLatencies:        clientLatencies,
+			GraphInternalLatencies: graphInternalLatencies,
+			StartTime:              stepResult.StartTime,
+			DurationMillis:         stepResult.DurationMillis,
+			BenchmarkFullyRun:      stepResult.BenchmarkFullyRun,
+			SLOs:                   stepResult.SLOs,
+		}
+		// the SLO p99 does not depend on the reported percentiles
+		p99 := float64(clientSide_AllQueries_OverallLatencies.ValueAtQuantile(99.0)) / 1000.0
+		rampStep.SLOBreaches = checkRampStepSLO(p99, errorRate, r.sloP99Millis, r.sloMaxErrorRate)
+		rampStep.MetSLO = len(rampStep.SLOBreaches) == 0
+		rampResult.Steps = append(rampResult.Steps, rampStep)
+		if !completed {
+			log.Printf("Ramp step %d was interrupted. Stopping the ramp\n", step+1)
+			break
+		}
+		if !rampStep.MetSLO {
+			log.Printf("Ramp step %d breached the SLOs: %s. Stopping the ramp\n", step+1, strings.Join(rampStep.SLOBreaches, ", "))
+			break
+		}
+		maxSustainableStep := rampStep
+		rampResult.MaxSustainableStep = &maxSustainableStep
+		if r.max > 0 && target >= r.max {
+			log.Printf("Ramp reached the max %s %d without breaching the SLOs\n", r.mode, r.max)
+			break
+		}
+	}
+	printRampSummary(rampResult)
+	return
+}
+
+func printRampSummary(rampResult *RampResult) {
+	writer := os.Stdout
+	fmt.Fprintf(writer, "## Ramp summary table\n")
+	table := tablewriter.NewWriter(writer)
+	header := []string{"Step", "Clients", "Target rps", "Achieved rps"}
+	for _, percentile := range getTablePercentiles() {
+		header = append(header, fmt.Sprintf("%s latency(ms)", getPercentileLabel(percentile)))
+	}
+	table.SetHeader(append(header, "Error rate(%)", "Met SLO"))
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, step := range rampResult.Steps {
+		targetRps := "unlimited"
+		if step.TargetRps > 0 {
+			targetRps = fmt.Sprintf("%d", step.TargetRps)
+		}
+		line := []string{
+			fmt.Sprintf("%d", step.Step),
+			fmt.Sprintf("%d", step.Clients),
+			targetRps,
+			fmt.Sprintf("%.0f", step.AchievedRps),
+		}
+		for _, percentile := range getTablePercentiles() {
+			line = append(line, fmt.Sprintf("%.3f", step.ClientLatencies[getPercentileKey(percentile)]))
+		}
+		table.Append(append(line, fmt.Sprintf("%.3f", step.ErrorRate), fmt.Sprintf("%t", step.MetSLO)))
+	}
+	table.Render()
+	if rampResult.MaxSustainableStep == nil {
+		fmt.Fprintf(writer, "No ramp step met the SLOs\n")
+	} else {
+		fmt.Fprintf(writer, "Max sustainable step: %d ( %d clients, target rps %d ) achieving %.0f requests per second\n", rampResult.MaxSustainableStep.Step, rampResult.MaxSustainableStep.Clients, rampResult.MaxSustainableStep.TargetRps, rampResult.MaxSustainableStep.AchievedRps)
+	}
+}
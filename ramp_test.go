@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_rampSettings_getStepTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings rampSettings
+		step     int
+		want     uint64
+	}{
+		{"first-step", rampSettings{start: 1000, step: 500, max: 0}, 0, 1000},
+		{"third-step", rampSettings{start: 1000, step: 500, max: 0}, 2, 2000},
+		{"capped-at-max", rampSettings{start: 1000, step: 500, max: 1800}, 2, 1800},
+		{"below-max", rampSettings{start: 1, step: 1, max: 10}, 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.getStepTarget(tt.step); got != tt.want {
+				t.Errorf("getStepTarget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkRampStepSLO(t *testing.T) {
+	tests := []struct {
+		name            string
+		p99Millis       float64
+		errorRate       float64
+		sloP99Millis    float64
+		sloMaxErrorRate float64
+		want            []string
+	}{
+		{"no-slos", 100, 50, 0, 0, []string{}},
+		{"met", 5, 0.1, 10, 1, []string{}},
+		{"p99-breached", 15, 0.1, 10, 1, []string{"p99 15.000 ms > 10.000 ms"}},
+		{"error-rate-breached", 5, 2, 10, 1, []string{"error rate 2.000 % > 1.000 %"}},
+		{"both-breached", 15, 2, 10, 1, []string{"p99 15.000 ms > 10.000 ms", "error rate 2.000 % > 1.000 %"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkRampStepSLO(tt.p99Millis, tt.errorRate, tt.sloP99Millis, tt.sloMaxErrorRate); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkRampStepSLO() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,27 +4,41 @@ import (
 	"encoding/csv"
 	"flag"
 	"fmt"
-	"github.com/RedisGraph/redisgraph-go"
 	redistimeseries "github.com/RedisTimeSeries/redistimeseries-go"
-	"github.com/gomodule/redigo/redis"
-	"golang.org/x/time/rate"
 	"log"
 	"math/rand"
 	"os"
-	"os/signal"
 	"strings"
-	"sync"
 	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		os.Exit(runMerge(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		os.Exit(runAgent(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		os.Exit(runReport(os.Args[2:]))
+	}
 	host := flag.String("h", "127.0.0.1", "Server hostname.")
 	port := flag.Int("p", 6379, "Server port.")
+	socket := flag.String("s", "", "Server socket (overrides host and port).")
 	tlsCaCertFile := flag.String("tls-ca-cert-file", "", "A PEM encoded CA's certificate file.")
-	rps := flag.Int64("rps", 0, "Max rps. If 0 no limit is applied and the DB is stressed up to maximum.")
+	rps := int64ListParameter{0}
+	flag.Var(&rps, "rps", "Max rps. If 0 no limit is applied and the DB is stressed up to maximum. A comma separated list ( e.g. 0,1000,5000 ) sweeps over each value.")
 	password := flag.String("a", "", "Password for Redis Auth.")
-	clients := flag.Uint64("c", 50, "number of clients.")
+	clients := uint64ListParameter{50}
+	flag.Var(&clients, "c", "number of clients. A comma separated list ( e.g. 10,50,100 ) sweeps over each value.")
 	numberRequests := flag.Uint64("n", 1000000, "Total number of requests")
+	warmupRequests := flag.Uint64("warmup-requests", 0, "Total number of requests to issue before starting to measure. Warmup requests are not accounted on the benchmark results.")
+	warmupTime := flag.Duration("warmup-time", 0, "Time to issue requests before starting to measure. If both -warmup-requests and -warmup-time are specified the warmup stops as soon as one of them is reached.")
+	pipeline := uint64ListParameter{1}
+	flag.Var(&pipeline, "pipeline", "Number of queries each client writes on its connection before reading the replies. 1 means no pipelining. A comma separated list ( e.g. 1,8,32 ) sweeps over each value.")
 	debug := flag.Int("debug", 0, "Client debug level.")
 	randomSeed := flag.Int64("random-seed", 12345, "Random seed to use.")
 	dataImportFile := flag.String("data-import-terms", "", "Read field replacement data from file in csv format. each column should start and end with '__' chars. Example __field1__,__field2__.")
@@ -34,20 +48,54 @@ func main() {
 	graphKey := flag.String("graph-key", "graph", "graph key.")
 	flag.Var(&benchmarkQueries, "query", "Specify a RedisGraph query to send in quotes. Each command that you specify is run with its ratio. For example: -query=\"CREATE (n)\" -query-ratio=1")
 	flag.Var(&benchmarkQueriesRO, "query-ro", "Specify a RedisGraph read-only query to send in quotes. You can run multiple commands (both read/write) on the same benchmark. Each command that you specify is run with its ratio. For example: -query=\"CREATE (n)\" -query-ratio=0.5 -query-ro=\"MATCH (n) RETURN n\" -query-ratio=0.5")
+	flag.Var(&replicaEndpoints, "replica", "Replica endpoint ( host:port ) to route the read-only queries to. Can be specified multiple times, in which case the clients are evenly distributed across the replicas. For example: -replica 10.0.0.2:6379 -replica 10.0.0.3:6379")
+	replicasDiscover := flag.Bool("replicas-discover", false, "Discover the replica endpoints via 'INFO replication' on the primary and route the read-only queries to them.")
+	flag.Var(&sentinelEndpoints, "sentinel", "Sentinel endpoint ( host:port ) used to resolve the current master. Can be specified multiple times. When specified, -h and -p are ignored and the master ( and the replicas read-only queries are routed to ) are re-resolved on failover.")
+	sentinelMaster := flag.String("sentinel-master", "", "Name of the master monitored by the sentinels.")
+	sentinelPollInterval := flag.Duration("sentinel-poll-interval", time.Second, "Period to re-resolve the master via sentinel, in order to detect failovers.")
+	sentinelFailoverTimeout := flag.Duration("sentinel-failover-timeout", time.Second*30, "Max time a client waits for a new master to be promoted after losing the connection to the current one.")
 	flag.Var(&benchmarkQueryRates, "query-ratio", "The query ratio vs other queries used in the same benchmark. Each command that you specify is run with its ratio. For example: -query=\"CREATE (n)\" -query-ratio=0.5 -query=\"MATCH (n) RETURN n\" -query-ratio=0.5")
 	jsonOutputFile := flag.String("json-out-file", "benchmark-results.json", "Name of json output file to output benchmark results. If not set, will not print to json.")
+	outputFormatParam := flag.String("output-format", outputFormatTable, "Format of the final summary tables. Either 'table', 'csv' ( one row per query with all metrics ), 'markdown' or 'html'.")
+	percentiles := flag.String("percentiles", "", "Comma separated list of the latency percentiles reported on every table, json results map and exported series, e.g. 50,90,99,99.9,99.99. When set, the json results maps also include the min, max and stddev. If empty, the default q0, q50, q95, q99, q999, q100 and avg keys are reported.")
 	cliUpdateTick := flag.Duration("reporting-period", time.Second*5, "Period to report stats.")
+	hdrIntervalLogFile := flag.String("hdr-interval-log-file", "", "Name of the HdrHistogram interval log ( .hlog ) file to output the client and RedisGraph internal latency histograms of each reporting period. If not set, will not output the interval log.")
 	// data sink
 	runName := flag.String("exporter-run-name", "perf-run", "Run name.")
 	rtsHost := flag.String("exporter-rts-host", "127.0.0.1", "RedisTimeSeries hostname.")
 	rtsPort := flag.Int("exporter-rts-port", 6379, "RedisTimeSeries port.")
 	rtsPassword := flag.String("exporter-rts-auth", "", "RedisTimeSeries Password for Redis Auth.")
 	var rtsAuth *string = nil
+	serverStats := flag.Bool("server-stats", false, "Sample the server memory, cpu, clients, graph commands stats and graph key memory usage on every reporting period, over a separate connection.")
+	slowlog := flag.Bool("slowlog", false, "Reset GRAPH.SLOWLOG on the primary and replicas before each run and collect it once the run finishes, reporting the slowest queries on the summary and json results. The reset discards the existing slowlog entries.")
+	slowlogPollPeriod := flag.Duration("slowlog-poll-period", 0, "Period to also fetch GRAPH.SLOWLOG along the run, given it only keeps a few of the slowest queries. If 0 it is only fetched once the run finishes.")
+	slowlogMaxEntries := flag.Int("slowlog-max-entries", 10, "Max number of slowest queries reported from GRAPH.SLOWLOG.")
+	metricsListen := flag.String("metrics-listen", "", "Address to expose the Prometheus /metrics endpoint on during the runs ( e.g. :9100 ). If empty the endpoint is not exposed.")
+	flag.Var(&exporterSpecs, "exporter", "Live exporter of the stats of each reporting period and of the final results. Can be specified multiple times. Either 'influx:<file>' ( InfluxDB line protocol ), 'csv:<file>', 'jsonl[:<file>]' ( JSON lines to the file, or to stdout in which case the progress lines go to stderr ) or 'statsd:<host:port>' ( StatsD gauges over UDP ). For example: -exporter influx:run.influx -exporter statsd:127.0.0.1:8125")
+	rtsQueueSize := flag.Int("exporter-rts-queue-size", 100, "Max number of reporting periods queued to be exported to RedisTimeSeries. When the queue is full the samples of the reporting period are dropped.")
+	rtsMaxRetries := flag.Int("exporter-rts-max-retries", 3, "Max number of times the export of a reporting period to RedisTimeSeries is retried on connection errors.")
 	rtsEnabled := flag.Bool("enable-exporter-rps", false, "Push results to redistimeseries exporter in real-time. Time granularity is set via the -reporting-period parameter.")
 	continueOnError := flag.Bool("continue-on-error", false, "Continue benchmark in case of error replies.")
 
-	var loopV = false
-	var loop *bool = &loopV
+	scenarioFile := flag.String("scenario-file", "", "Read a multi-phase scenario from a json file. Each phase runs in order with its own queries, clients, requests (or duration) and rps, falling back to the command line parameters for any unset setting. When specified, -query, -query-ro and -query-ratio are ignored.")
+	repetitions := flag.Int("repetitions", 1, "Number of times to run the same benchmark. When greater than 1, the mean, median, stddev, min, max and 95% confidence interval of the throughput and of each latency quantile are reported across the repetitions.")
+	repetitionsMaxDeviation := flag.Float64("repetitions-max-deviation", 10, "Max deviation percentage of a repetition throughput and latencies from their median across the repetitions. Repetitions exceeding it are flagged as high variance. If 0 it is not checked.")
+	sweepResetGraph := flag.Bool("sweep-reset-graph", false, "Delete the graph in between each of the sweep combinations.")
+	sweepCsvOutputFile := flag.String("sweep-csv-out-file", "", "Name of the csv output file to output the combined sweep results. If not set, will not print to csv.")
+	rampMode := flag.String("ramp", "", "Stepped load ramp mode, either 'rps' or 'clients'. On each step of -ramp-step-duration the target rps ( or clients ) is increased by -ramp-step, up until -ramp-max is reached or the step breaches the SLOs. If empty no ramp is done.")
+	rampStart := flag.Uint64("ramp-start", 1000, "Target rps ( or clients ) of the first ramp step.")
+	rampStep := flag.Uint64("ramp-step", 1000, "Target rps ( or clients ) increase on each ramp step.")
+	rampMax := flag.Uint64("ramp-max", 0, "Max target rps ( or clients ) of the ramp. If 0 the ramp only stops when the SLOs are breached.")
+	rampStepDuration := flag.Duration("ramp-step-duration", time.Second*30, "Duration of each ramp step.")
+	rampSloP99 := flag.Float64("ramp-slo-p99", 0, "Max client p99 latency in milliseconds a ramp step needs to achieve to meet the SLO. If 0 it is not checked.")
+	rampSloMaxErrorRate := flag.Float64("ramp-slo-max-error-rate", 0, "Max error rate percentage a ramp step needs to achieve to meet the SLO. If 0 it is not checked.")
+
+	flag.Var(&sloSpecs, "slo", "Service level objective evaluated against the final results, in the format [<query>:]<metric><operator><threshold>. Metrics are pNN and avg ( client latency in ms ), internal-pNN and internal-avg ( RedisGraph internal execution time in ms ), error-rate ( % ) and throughput ( requests per second ). Can be specified multiple times. If any SLO fails the exit code is 1. For example: -slo \"p99<5\" -slo \"error-rate<0.1\" -slo \"MATCH (n) RETURN n:throughput>20000\"")
+
+	flag.Var(&agentEndpoints, "agent", "Agent endpoint ( host:port ) to distribute the benchmark to. Can be specified multiple times, in which case the clients, requests and rps are evenly split across the agents. Agents are started via the 'agent' subcommand. For example: -agent 10.0.0.5:8181 -agent 10.0.0.6:8181")
+	agentToken := flag.String("agent-token", "", "Shared token sent to the agents ( see the agent -token ). The coordinator sends the -a password to the agents, so the agents should require a token and be reached via HTTPS ( see -agent-tls-ca-cert-file ) outside of trusted networks.")
+	agentTlsCaCertFile := flag.String("agent-tls-ca-cert-file", "", "A PEM encoded CA's certificate file the agents certificates are verified against. When specified the agents are reached via HTTPS.")
+
 	version := flag.Bool("v", false, "Output version and exit")
 	flag.Parse()
 
@@ -64,46 +112,124 @@ func main() {
 		rtsAuth = rtsPassword
 	}
 	var rtsClient *redistimeseries.Client = nil
+	var rtsExp *rtsExporter = nil
+	exporters := []benchmarkExporter{}
 	if *rtsEnabled == true {
 		log.Printf("Creating RTS client.\n")
 		rtsClient = redistimeseries.NewClient(fmt.Sprintf("%s:%d", *rtsHost, *rtsPort), "redisgraph-rts-client", rtsAuth)
+		rtsExp = newRtsExporter(rtsClient, *rtsQueueSize, *rtsMaxRetries)
+		exporters = append(exporters, rtsExp)
 	} else {
 		log.Printf("RTS export disabled.\n")
 	}
+	for _, spec := range exporterSpecs {
+		exporter, err := newExporter(spec)
+		if err != nil {
+			log.Fatalf("Invalid -exporter parameter '%s'. Error: %v", spec, err)
+		}
+		exporters = append(exporters, exporter)
+	}
+	var benchmarkScenario scenario
 	totalQueries := len(benchmarkQueries) + len(benchmarkQueriesRO)
-	if totalQueries < 1 {
+	if *scenarioFile != "" {
+		var err error
+		benchmarkScenario, err = loadScenario(*scenarioFile)
+		if err != nil {
+			log.Fatalf("Unable to load scenario file %s. Error: %v", *scenarioFile, err)
+		}
+		log.Printf("Loaded scenario file %s with %d phases.\n", *scenarioFile, len(benchmarkScenario.Phases))
+	} else if totalQueries < 1 {
 		log.Fatalf("You need to specify at least a query with the -query parameter or -query-ro. For example: -query=\"CREATE (n)\"")
 	}
+	for _, p := range pipeline {
+		if p < 1 {
+			log.Fatalf("The -pipeline parameter needs to be at least 1.")
+		}
+	}
+	for _, c := range clients {
+		if c < 1 {
+			log.Fatalf("The -c parameter needs to be at least 1.")
+		}
+	}
+	sweepEnabled := len(clients) > 1 || len(rps) > 1 || len(pipeline) > 1
+	if sweepEnabled && (*scenarioFile != "" || *rampMode != "") {
+		log.Fatalf("A sweep ( a list of values on -c, -rps or -pipeline ) can not be used together with -scenario-file or -ramp.")
+	}
+	if len(agentEndpoints) > 0 {
+		if sweepEnabled || *scenarioFile != "" || *rampMode != "" || *repetitions > 1 {
+			log.Fatalf("The -agent parameter can not be used together with a sweep, -scenario-file, -ramp or -repetitions.")
+		}
+		if len(sentinelEndpoints) > 0 || *dataImportFile != "" {
+			log.Fatalf("The -agent parameter can not be used together with -sentinel or -data-import-terms.")
+		}
+		if clients[0] < uint64(len(agentEndpoints)) {
+			log.Fatalf("The -c parameter needs to be at least the number of agents.")
+		}
+		if *numberRequests < uint64(len(agentEndpoints)) {
+			log.Fatalf("The -n parameter needs to be at least the number of agents.")
+		}
+		// an agent share of 0 rps would mean no limit on that agent
+		if rps[0] > 0 && rps[0] < int64(len(agentEndpoints)) {
+			log.Fatalf("The -rps parameter needs to be either 0 or at least the number of agents.")
+		}
+	}
+	if *repetitions < 1 {
+		log.Fatalf("The -repetitions parameter needs to be at least 1.")
+	}
+	if *repetitions > 1 && (sweepEnabled || *scenarioFile != "" || *rampMode != "") {
+		log.Fatalf("The -repetitions parameter can not be used together with a sweep, -scenario-file or -ramp.")
+	}
+	ramp := rampSettings{mode: *rampMode, start: *rampStart, step: *rampStep, max: *rampMax, stepDuration: *rampStepDuration, sloP99Millis: *rampSloP99, sloMaxErrorRate: *rampSloMaxErrorRate}
+	if ramp.mode != "" {
+		if ramp.mode != rampModeRps && ramp.mode != rampModeClients {
+			log.Fatalf("Invalid -ramp mode '%s'. Either '%s' or '%s'.", ramp.mode, rampModeRps, rampModeClients)
+		}
+		if *scenarioFile != "" {
+			log.Fatalf("The -ramp and -scenario-file parameters can not be used together.")
+		}
+		if ramp.max == 0 && ramp.sloP99Millis == 0 && ramp.sloMaxErrorRate == 0 {
+			log.Fatalf("You need to specify either -ramp-max or at least one SLO ( -ramp-slo-p99 or -ramp-slo-max-error-rate ) for the ramp to stop.")
+		}
+		if ramp.start == 0 || ramp.step == 0 {
+			log.Fatalf("The -ramp-start and -ramp-step parameters need to be at least 1.")
+		}
+	}
+	if !isValidOutputFormat(*outputFormatParam) {
+		log.Fatalf("Invalid -output-format '%s'. Either '%s', '%s', '%s' or '%s'.", *outputFormatParam, outputFormatTable, outputFormatCsv, outputFormatMarkdown, outputFormatHtml)
+	}
+	outputFormat = *outputFormatParam
+	var err error
+	if *percentiles != "" {
+		reportedPercentiles, err = parsePercentiles(*percentiles)
+		if err != nil {
+			log.Fatalf("Invalid -percentiles parameter. Error: %v", err)
+		}
+		customPercentiles = true
+	}
 	log.Printf("Debug level: %d.\n", *debug)
 	log.Printf("Using random seed: %d.\n", *randomSeed)
 	rand.Seed(*randomSeed)
 	randLimit := *randomIntMax - *randomIntMin
-	testResult := NewTestResult("", uint(*clients), *numberRequests, uint64(*rps), "")
-	testResult.SetUsedRandomSeed(*randomSeed)
-
-	var requestRate = Inf
-	var requestBurst = 1
-	useRateLimiter := false
-	if *rps != 0 {
-		requestRate = rate.Limit(*rps)
-		requestBurst = int(*clients)
-		useRateLimiter = true
-	}
-
-	var rateLimiter = rate.NewLimiter(requestRate, requestBurst)
-	samplesPerClient := *numberRequests / *clients
-	samplesPerClientRemainder := *numberRequests % *clients
 
 	connectionStr := fmt.Sprintf("%s:%d", *host, *port)
-	// a WaitGroup for the goroutines to tell us they've stopped
-	wg := sync.WaitGroup{}
-	if !*loop {
-		log.Printf("Total clients: %d. Commands per client: %d Total commands: %d\n", *clients, samplesPerClient, *numberRequests)
-		if samplesPerClientRemainder != 0 {
-			log.Printf("Last client will issue: %d commands.\n", samplesPerClientRemainder+samplesPerClient)
+	network := "tcp"
+	if *socket != "" {
+		connectionStr = *socket
+		network = "unix"
+	}
+	var resolver *sentinelResolver = nil
+	if len(sentinelEndpoints) > 0 {
+		if *sentinelMaster == "" {
+			log.Fatalf("You need to specify the master name with the -sentinel-master parameter when using -sentinel.")
 		}
-	} else {
-		log.Printf("Running in loop until you hit Ctrl+C\n")
+		var err error
+		resolver, err = newSentinelResolver(sentinelEndpoints, *sentinelMaster)
+		if err != nil {
+			log.Fatalf("Unable to resolve master '%s' via sentinel. Error: %v", *sentinelMaster, err)
+		}
+		connectionStr, _ = resolver.Master()
+		network = "tcp"
+		log.Printf("Resolved master '%s' via sentinel: %s\n", *sentinelMaster, connectionStr)
 	}
 
 	queries := make([]string, totalQueries)
@@ -155,118 +281,232 @@ func main() {
 			queryIsReadOnly[i] = true
 		}
 	}
-	totalDifferentCommands, cdf := prepareCommandsDistribution(readAndWriteQueries, queries, cmdRates)
-
-	createRequiredGlobalStructs(totalDifferentCommands)
-
-	rgs := make([]redisgraph.Graph, *clients)
-	conns := make([]redis.Conn, *clients)
-
-	// a WaitGroup for the goroutines to tell us they've stopped
-	dataPointProcessingWg := sync.WaitGroup{}
-	graphDatapointsChann := make(chan GraphQueryDatapoint, *clients)
-
-	// listen for C-c
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	var cdf []float32
+	if totalQueries > 0 {
+		_, cdf = prepareCommandsDistribution(readAndWriteQueries, benchmarkQueryRates, queries, cmdRates)
+	}
+	slos, err := parseSLOs(sloSpecs, queries)
+	if err != nil {
+		log.Fatalf("Invalid -slo parameter. Error: %v", err)
+	}
 
-	c1 := make(chan os.Signal, 1)
-	signal.Notify(c1, os.Interrupt)
+	log.Printf("Connecting to %s using %s transport\n", connectionStr, getTransport(network, *tlsCaCertFile))
+	_, graphConn := getStandaloneConn(*graphKey, network, connectionStr, *password, *tlsCaCertFile)
+	replicas := []string(replicaEndpoints)
+	if *replicasDiscover {
+		var discoveredReplicas []string
+		var err error
+		if resolver != nil {
+			log.Printf("Trying to discover replicas via sentinel\n")
+			discoveredReplicas, err = resolver.resolveReplicas()
+		} else {
+			log.Printf("Trying to discover replicas via INFO replication\n")
+			discoveredReplicas, err = discoverReplicas(graphConn)
+		}
+		if err != nil {
+			log.Fatalf("Unable to discover replicas. Error: %v", err)
+		}
+		log.Printf("Discovered %d online replicas: %v\n", len(discoveredReplicas), discoveredReplicas)
+		replicas = append(replicas, discoveredReplicas...)
+	}
+	// endpoint 0 is the primary. read-only queries are routed to the replicas if there are any.
+	endpoints := append([]string{connectionStr}, replicas...)
+	if len(replicas) > 0 {
+		log.Printf("Routing read-only queries to %d replicas: %v\n", len(replicas), replicas)
+	}
 
-	graphC, _ := getStandaloneConn(*graphKey, "tcp", connectionStr, *password, *tlsCaCertFile)
 	log.Printf("Trying to extract RedisGraph version info\n")
 
-	redisgraphVersion, err := getRedisGraphVersion(graphC)
+	module, err := getGraphModule(graphConn)
 	if err != nil {
 		log.Println(fmt.Sprintf("Unable to retrieve RedisGraph version. Continuing anayway. Error: %v\n", err))
 	} else {
-		log.Println(fmt.Sprintf("Detected RedisGraph version %d\n", redisgraphVersion))
+		log.Println(fmt.Sprintf("Detected %s version %s ( module %s, version %d )\n", module.Flavor, module.SemanticVersion, module.Name, module.Version))
 	}
+	redisgraphVersion := module.Version
+	// captured before the benchmark, given the memory fields change along the run
+	serverConfigs := getServerConfigs(graphConn)
 
-	tick := time.NewTicker(*cliUpdateTick)
-
-	dataPointProcessingWg.Add(1)
-	go processGraphDatapointsChannel(graphDatapointsChann, c1, *numberRequests, &dataPointProcessingWg, &instantHistogramsResetMutex)
-	// Total commands to be issue per client. Equal for all clients with exception of the last one ( see comment bellow )
-	clientTotalCmds := samplesPerClient
-	startTime := time.Now()
-	for client_id := 0; uint64(client_id) < *clients; client_id++ {
-		wg.Add(1)
-		rgs[client_id], conns[client_id] = getStandaloneConn(*graphKey, "tcp", connectionStr, *password, *tlsCaCertFile)
-		// Given the total commands might not be divisible by the #clients
-		// the last client will send the remainder commands to match the desired request count.
-		// It's OK to alter clientTotalCmds given this is the last time we use it's value
-		if uint64(client_id) == (*clients - uint64(1)) {
-			clientTotalCmds = samplesPerClientRemainder + samplesPerClient
-		}
-		cmdStartPos := uint64(client_id) * samplesPerClient
-		go ingestionRoutine(&rgs[client_id], *continueOnError, queries, queryIsReadOnly, cdf, *randomIntMin, randLimit, clientTotalCmds, *loop, *debug, &wg, useRateLimiter, rateLimiter, graphDatapointsChann, dataReplacementEnabled, replacementArr, cmdStartPos)
+	stopSentinelWatch := make(chan struct{})
+	if resolver != nil {
+		go resolver.watchFailovers(*sentinelPollInterval, stopSentinelWatch)
 	}
 
-	// enter the update loopupdateCLIupdateCLI
-	updateCLI(startTime, tick, c, *numberRequests, *loop, rtsClient, *runName)
-
-	endTime := time.Now()
-	duration := time.Since(startTime)
+	if *metricsListen != "" {
+		if err = startMetricsServer(*metricsListen); err != nil {
+			log.Fatalf("Unable to expose the metrics endpoint on %s. Error: %v", *metricsListen, err)
+		}
+	}
 
-	// benchmarked ended, close the connections
-	for _, standaloneConn := range conns {
-		standaloneConn.Close()
+	var hlog *hdrIntervalLog = nil
+	if *hdrIntervalLogFile != "" {
+		hlog, err = newHdrIntervalLog(*hdrIntervalLogFile, time.Now())
+		if err != nil {
+			log.Fatalf("Unable to create the HdrHistogram interval log file %s. Error: %v", *hdrIntervalLogFile, err)
+		}
+		defer hlog.Close()
+		log.Printf("Writing the HdrHistogram interval log to %s\n", *hdrIntervalLogFile)
 	}
 
-	//wait for all stats to be processed
-	dataPointProcessingWg.Wait()
+	runner := &benchmarkRunner{
+		graphKey:               *graphKey,
+		network:                network,
+		connectionStr:          connectionStr,
+		password:               *password,
+		tlsCaCertFile:          *tlsCaCertFile,
+		resolver:               resolver,
+		failoverTimeout:        *sentinelFailoverTimeout,
+		endpoints:              endpoints,
+		continueOnError:        *continueOnError,
+		debug:                  *debug,
+		randomIntMin:           *randomIntMin,
+		randLimit:              randLimit,
+		dataReplacementEnabled: dataReplacementEnabled,
+		replacementArr:         replacementArr,
+		cliUpdateTick:          *cliUpdateTick,
+		exporters:              exporters,
+		runName:                *runName,
+		gitSHA:                 git_sha,
+		redisgraphVersion:      redisgraphVersion,
+		serverStats:            *serverStats,
+		slowlog:                *slowlog,
+		slowlogPollPeriod:      *slowlogPollPeriod,
+		slowlogMaxEntries:      *slowlogMaxEntries,
+	}
+	if hlog != nil {
+		runner.intervalOutput = hlog
+	}
+	workload := benchmarkWorkload{
+		queries:         queries,
+		queryIsReadOnly: queryIsReadOnly,
+		cmdRates:        cmdRates,
+		cdf:             cdf,
+		clients:         clients[0],
+		numberRequests:  *numberRequests,
+		rps:             rps[0],
+		pipeline:        pipeline[0],
+		warmupRequests:  *warmupRequests,
+		warmupTime:      *warmupTime,
+		slos:            slos,
+	}
 
-	testResult.FillDurationInfo(startTime, endTime, duration)
-	testResult.BenchmarkFullyRun = totalCommands == *numberRequests
-	testResult.IssuedCommands = totalCommands
-	overallGraphInternalLatencies, internalLatencyMap := GetOverallLatencies(queries, serverSide_PerQuery_GraphInternalTime_OverallLatencies, serverSide_AllQueries_GraphInternalTime_OverallLatencies)
-	overallClientLatencies, clientLatencyMap := GetOverallLatencies(queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
-	relativeLatencyDiff, absoluteLatencyDiff := GenerateInternalExternalRatioLatencies(internalLatencyMap, clientLatencyMap)
-	testResult.OverallClientLatencies = overallClientLatencies
-	testResult.OverallGraphInternalLatencies = overallGraphInternalLatencies
-	testResult.AbsoluteInternalExternalLatencyDiff = absoluteLatencyDiff
-	testResult.RelativeInternalExternalLatencyDiff = relativeLatencyDiff
-	testResult.OverallQueryRates = GetOverallRatesMap(duration, queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies)
-	testResult.DBSpecificConfigs = GetDBConfigsMap(redisgraphVersion)
-	testResult.Totals = GetTotalsMap(queries, clientSide_PerQuery_OverallLatencies, clientSide_AllQueries_OverallLatencies, errorsPerQuery, totalNodesCreatedPerQuery, totalNodesDeletedPerQuery, totalLabelsAddedPerQuery, totalPropertiesSetPerQuery, totalRelationshipsCreatedPerQuery, totalRelationshipsDeletedPerQuery)
+	var testResult *TestResult
+	if *scenarioFile != "" {
+		startTime := time.Now()
+		phaseResults, completed := runScenario(runner, benchmarkScenario, workload)
+		endTime := time.Now()
+		testResult = NewTestResult("", 0, 0, 0, *scenarioFile)
+		testResult.FillDurationInfo(startTime, endTime, endTime.Sub(startTime))
+		testResult.Phases = phaseResults
+		testResult.BenchmarkFullyRun = completed
+		for _, phaseResult := range phaseResults {
+			if phaseResult.Result != nil {
+				testResult.IssuedCommands += phaseResult.Result.IssuedCommands
+			}
+		}
+	} else if ramp.mode != "" {
+		startTime := time.Now()
+		rampResult, completed := runRamp(runner, ramp, workload)
+		endTime := time.Now()
+		testResult = NewTestResult("", 0, 0, 0, fmt.Sprintf("%s ramp", ramp.mode))
+		testResult.FillDurationInfo(startTime, endTime, endTime.Sub(startTime))
+		testResult.Ramp = rampResult
+		testResult.BenchmarkFullyRun = completed
+		for _, step := range rampResult.Steps {
+			testResult.IssuedCommands += step.IssuedCommands
+		}
+	} else if sweepEnabled {
+		startTime := time.Now()
+		sweepResults, completed := runSweep(runner, getSweepWorkloads(workload, clients, rps, pipeline), *sweepResetGraph)
+		endTime := time.Now()
+		testResult = NewTestResult("", 0, *numberRequests, 0, "sweep")
+		testResult.FillDurationInfo(startTime, endTime, endTime.Sub(startTime))
+		testResult.Sweep = sweepResults
+		testResult.BenchmarkFullyRun = completed
+		for _, point := range sweepResults {
+			testResult.IssuedCommands += point.IssuedCommands
+		}
+		if *sweepCsvOutputFile != "" {
+			if err := saveSweepCsv(sweepResults, *sweepCsvOutputFile); err != nil {
+				log.Fatalf("Unable to save the sweep CSV results file %s. Error: %v", *sweepCsvOutputFile, err)
+			}
+		}
+	} else if len(agentEndpoints) > 0 {
+		client, err := newAgentClient(*agentToken, *agentTlsCaCertFile)
+		if err != nil {
+			log.Fatalf("Unable to load the agents CA certificate file %s. Error: %v", *agentTlsCaCertFile, err)
+		}
+		if testResult, err = runDistributed(agentEndpoints, client, runner, workload, *randomSeed); err != nil {
+			log.Fatalf("Unable to run the distributed benchmark. Error: %v", err)
+		}
+	} else if *repetitions > 1 {
+		startTime := time.Now()
+		repetitionsResult, completed := runRepetitions(runner, workload, *repetitions, *repetitionsMaxDeviation)
+		endTime := time.Now()
+		testResult = NewTestResult("", uint(workload.clients), workload.numberRequests, uint64(workload.rps), fmt.Sprintf("%d repetitions", *repetitions))
+		testResult.FillDurationInfo(startTime, endTime, endTime.Sub(startTime))
+		testResult.Repetitions = repetitionsResult
+		testResult.BenchmarkFullyRun = completed
+		for _, run := range repetitionsResult.Runs {
+			testResult.IssuedCommands += run.IssuedCommands
+		}
+	} else {
+		var completed bool
+		if testResult, completed = runner.run(workload); !completed {
+			log.Println("The benchmark was interrupted. Only the partial results are reported")
+		}
+	}
+	close(stopSentinelWatch)
+	for _, exporter := range exporters {
+		if err := exporter.close(); err != nil {
+			log.Printf("Unable to close the exporter. Error: %v\n", err)
+		}
+	}
+	// only known once the queued samples were exported
+	if rtsExp != nil {
+		rtsStats := rtsExp.stats()
+		testResult.RedisTimeSeriesExporter = &rtsStats
+	}
+	testResult.SetUsedRandomSeed(*randomSeed)
 
-	// final merge of pending stats
-	printFinalSummary(queries, cmdRates, totalCommands, duration)
+	if resolver != nil {
+		testResult.FailoverEvents = resolver.FailoverEvents()
+		log.Printf("Detected %d failovers during the benchmark\n", len(testResult.FailoverEvents))
+	}
+	testResult.DBSpecificConfigs = GetDBConfigsMap(module, getTransport(network, *tlsCaCertFile), serverConfigs)
 
 	if strings.Compare(*jsonOutputFile, "") != 0 {
 		saveJsonResult(testResult, jsonOutputFile)
 	}
+	if failedSLOs := testResult.FailedSLOs(); failedSLOs > 0 {
+		log.Printf("%d SLOs failed\n", failedSLOs)
+		os.Exit(1)
+	}
 }
 
-func GetDBConfigsMap(version int64) map[string]interface{} {
+func GetDBConfigsMap(module graphModule, transport string, serverConfigs map[string]interface{}) map[string]interface{} {
 	dbConfigsMap := map[string]interface{}{}
-	dbConfigsMap["RedisGraphVersion"] = version
+	for k, v := range serverConfigs {
+		dbConfigsMap[k] = v
+	}
+	dbConfigsMap["RedisGraphVersion"] = module.Version
+	// empty if the graph module was not detected
+	dbConfigsMap["RedisGraphSemanticVersion"] = module.SemanticVersion
+	dbConfigsMap["GraphModuleName"] = module.Name
+	dbConfigsMap["GraphModuleFlavor"] = module.Flavor
+	dbConfigsMap["Transport"] = transport
 	return dbConfigsMap
 }
 
-// getRedisGraphVersion returns RedisGraph version by issuing "MODULE LIST" command
-// and iterating through the availabe modules up until "graph" is found as the name property
-func getRedisGraphVersion(graphClient redisgraph.Graph) (version int64, err error) {
-	var values []interface{}
-	var moduleInfo []interface{}
-	var moduleName string
-	values, err = redis.Values(graphClient.Conn.Do("MODULE", "LIST"))
-	if err != nil {
-		return
+// getTransport returns the transport used to connect to the primary ( unix, tcp, tls or unix+tls )
+// so that client RTT comparisons across different transports are not mixed up
+func getTransport(network string, tlsCaCertFile string) string {
+	if tlsCaCertFile == "" {
+		return network
 	}
-	for _, rawModule := range values {
-		moduleInfo, err = redis.Values(rawModule, err)
-		if err != nil {
-			return
-		}
-		moduleName, err = redis.String(moduleInfo[1], err)
-		if err != nil {
-			return
-		}
-		if moduleName == "graph" {
-			version, err = redis.Int64(moduleInfo[3], err)
-		}
+	if network == "tcp" {
+		return "tls"
 	}
-	return
+	// TLS is dialed on top of any network
+	return network + "+tls"
 }
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func Test_getTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		network       string
+		tlsCaCertFile string
+		want          string
+	}{
+		{"tcp", "tcp", "", "tcp"},
+		{"tls", "tcp", "ca.crt", "tls"},
+		{"unix", "unix", "", "unix"},
+		{"unix-with-tls", "unix", "ca.crt", "unix+tls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getTransport(tt.network, tt.tlsCaCertFile); got != tt.want {
+				t.Errorf("getTransport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
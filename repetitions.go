@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"log"
+	"math"
+	"os"
+	"sort"
+)
+
+// two-sided 95% Student's t critical values, indexed by degrees of freedom - 1
+var tCritical95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+// RepetitionStats holds the aggregation of a single metric across all repetitions
+type RepetitionStats struct {
+	Mean   float64 `json:"Mean"`
+	Median float64 `json:"Median"`
+	StdDev float64 `json:"StdDev"`
+	Min    float64 `json:"Min"`
+	Max    float64 `json:"Max"`
+	// 95% confidence interval of the mean
+	CILower float64 `json:"CILower"`
+	CIUpper float64 `json:"CIUpper"`
+	// StdDev relative to the Mean, in percentage
+	CoefficientOfVariation float64 `json:"CoefficientOfVariation"`
+}
+
+// RepetitionRunDeviation holds how far a single repetition deviates from the median of all repetitions
+type RepetitionRunDeviation struct {
+	Repetition int `json:"Repetition"`
+	// largest deviation of the repetition metrics from their median, in percentage of the median
+	MaxDeviation float64 `json:"MaxDeviation"`
+	// metric the largest deviation was observed on
+	Metric       string `json:"Metric"`
+	HighVariance bool   `json:"HighVariance"`
+}
+
+type RepetitionsResult struct {
+	Repetitions            int                        `json:"Repetitions"`
+	MaxDeviation           float64                    `json:"MaxDeviation"`
+	Throughput             RepetitionStats            `json:"Throughput"`
+	ClientLatencies        map[string]RepetitionStats `json:"ClientLatencies"`
+	GraphInternalLatencies map[string]RepetitionStats `json:"GraphInternalLatencies"`
+	RunDeviations          []RepetitionRunDeviation   `json:"RunDeviations"`
+	// true if any of the repetitions deviates more than MaxDeviation from the median
+	HighVariance bool          `json:"HighVariance"`
+	Runs         []*TestResult `json:"Runs"`
+}
+
+// getRepetitionStats aggregates the values of a metric across the repetitions
+func getRepetitionStats(values []float64) (stats RepetitionStats) {
+	n := len(values)
+	if n == 0 {
+		return
+	}
+	sorted := make([]float64, n)
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	stats.Min = sorted[0]
+	stats.Max = sorted[n-1]
+	if n%2 == 1 {
+		stats.Median = sorted[n/2]
+	} else {
+		stats.Median = (sorted[n/2-1] + sorted[n/2]) / 2.0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	stats.Mean = sum / float64(n)
+	stats.CILower = stats.Mean
+	stats.CIUpper = stats.Mean
+	if n > 1 {
+		squaredDiffs := 0.0
+		for _, v := range values {
+			squaredDiffs += (v - stats.Mean) * (v - stats.Mean)
+		}
+		// sample standard deviation
+		stats.StdDev = math.Sqrt(squaredDiffs / float64(n-1))
+		t := 1.960
+		if n-1 <= len(tCritical95) {
+			t = tCritical95[n-2]
+		}
+		margin := t * stats.StdDev / math.Sqrt(float64(n))
+		stats.CILower = stats.Mean - margin
+		stats.CIUpper = stats.Mean + margin
+	}
+	if stats.Mean != 0 {
+		stats.CoefficientOfVariation = stats.StdDev / stats.Mean * 100.0
+	}
+	return
+}
+
+// getRunDeviations returns, for each repetition, the metric that deviates the most from its median across the repetitions.
+// The median is used given a single outlier repetition would drag the mean, making the others deviate too.
+// metricValues holds the values of each of the metricNames, one per repetition. Repetitions deviating more than
+// maxDeviation percent are flagged as high variance. If maxDeviation is 0 none is
+func getRunDeviations(metricNames []string, metricValues map[string][]float64, repetitions int, maxDeviation float64) []RepetitionRunDeviation {
+	deviations := make([]RepetitionRunDeviation, repetitions)
+	for i := range deviations {
+		deviations[i].Repetition = i + 1
+	}
+	for _, metric := range metricNames {
+		values := metricValues[metric]
+		median := getRepetitionStats(values).Median
+		if median == 0 {
+			continue
+		}
+		for i, v := range values {
+			deviation := math.Abs(v-median) / median * 100.0
+			if deviation > deviations[i].MaxDeviation {
+				deviations[i].MaxDeviation = deviation
+				deviations[i].Metric = metric
+			}
+		}
+	}
+	for i := range deviations {
+		deviations[i].HighVariance = maxDeviation > 0 && deviations[i].MaxDeviation > maxDeviation
+	}
+	return deviations
+}
+
+func getRunThroughput(r *TestResult) float64 {
+	return float64(r.IssuedCommands) / (float64(r.DurationMillis) / 1000.0)
+}
+
+// runRepetitions runs the same workload the given number of times and aggregates the results.
+// The repetitions deviating more than maxDeviation percent from the median are flagged as high variance
+func runRepetitions(runner *benchmarkRunner, workload benchmarkWorkload, repetitions int, maxDeviation float64) (result *RepetitionsResult, completed bool) {
+	result = &RepetitionsResult{MaxDeviation: maxDeviation, Runs: make([]*TestResult, 0, repetitions)}
+	throughputs := make([]float64, 0, repetitions)
+	clientLatencies := map[string][]float64{}
+	graphInternalLatencies := map[string][]float64{}
+	completed = true
+	for i := 0; i < repetitions; i++ {
+		w := workload
+		w.name = fmt.Sprintf("repetition %d", i+1)
+		log.Printf("Running repetition %d/%d\n", i+1, repetitions)
+		var runResult *TestResult
+		runResult, completed = runner.run(w)
+		if !completed {
+			log.Printf("Repetition %d was interrupted. Skipping the remaining repetitions\n", i+1)
+			break
+		}
+		result.Runs = append(result.Runs, runResult)
+		throughputs = append(throughputs, getRunThroughput(runResult))
+		_, runClientLatencies := generateLatenciesMap(clientSide_AllQueries_OverallLatencies)
+		_, runGraphInternalLatencies := generateLatenciesMap(serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+		for _, key := range getLatencyMapKeys() {
+			clientLatencies[key] = append(clientLatencies[key], runClientLatencies[key])
+			graphInternalLatencies[key] = append(graphInternalLatencies[key], runGraphInternalLatencies[key])
+		}
+	}
+	result.Repetitions = len(result.Runs)
+	result.Throughput = getRepetitionStats(throughputs)
+	result.ClientLatencies = map[string]RepetitionStats{}
+	result.GraphInternalLatencies = map[string]RepetitionStats{}
+	metricNames := []string{"Throughput (rps)"}
+	metricValues := map[string][]float64{"Throughput (rps)": throughputs}
+	for _, key := range getLatencyMapKeys() {
+		result.ClientLatencies[key] = getRepetitionStats(clientLatencies[key])
+		result.GraphInternalLatencies[key] = getRepetitionStats(graphInternalLatencies[key])
+		clientMetric := fmt.Sprintf("Client latency %s (ms)", key)
+		graphInternalMetric := fmt.Sprintf("Graph internal time %s (ms)", key)
+		metricNames = append(metricNames, clientMetric, graphInternalMetric)
+		metricValues[clientMetric] = clientLatencies[key]
+		metricValues[graphInternalMetric] = graphInternalLatencies[key]
+	}
+	result.RunDeviations = getRunDeviations(metricNames, metricValues, result.Repetitions, maxDeviation)
+	highVarianceRuns := []int{}
+	for _, deviation := range result.RunDeviations {
+		if deviation.HighVariance {
+			highVarianceRuns = append(highVarianceRuns, deviation.Repetition)
+		}
+	}
+	result.HighVariance = len(highVarianceRuns) > 0
+	printRepetitionsSummary(result)
+	if result.HighVariance {
+		log.Printf("WARNING: repetitions %v deviate more than %.1f %% from the median of the %d repetitions. Results are noisy.\n", highVarianceRuns, maxDeviation, result.Repetitions)
+	}
+	return
+}
+
+func printRepetitionsSummary(result *RepetitionsResult) {
+	writer := os.Stdout
+	fmt.Fprintf(writer, "## Repetitions summary table ( %d repetitions )\n", result.Repetitions)
+	table := tablewriter.NewWriter(writer)
+	table.SetHeader([]string{"Metric", "Mean", "Median", "StdDev", "Min", "Max", "95% CI", "CV(%)"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	insertRepetitionsTableLine(table, "Throughput (rps)", result.Throughput)
+	for _, key := range getLatencyMapKeys() {
+		insertRepetitionsTableLine(table, fmt.Sprintf("Client latency %s (ms)", key), result.ClientLatencies[key])
+	}
+	for _, key := range getLatencyMapKeys() {
+		insertRepetitionsTableLine(table, fmt.Sprintf("Graph internal time %s (ms)", key), result.GraphInternalLatencies[key])
+	}
+	table.Render()
+
+	fmt.Fprintf(writer, "\n## Repetitions deviation table\n")
+	table = tablewriter.NewWriter(writer)
+	table.SetHeader([]string{"Repetition", "Throughput (rps)", "Max deviation(%)", "Metric", "High variance"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for i, deviation := range result.RunDeviations {
+		table.Append([]string{
+			fmt.Sprintf("%d", deviation.Repetition),
+			fmt.Sprintf("%.3f", getRunThroughput(result.Runs[i])),
+			fmt.Sprintf("%.1f", deviation.MaxDeviation),
+			deviation.Metric,
+			fmt.Sprintf("%t", deviation.HighVariance),
+		})
+	}
+	table.Render()
+}
+
+func insertRepetitionsTableLine(table *tablewriter.Table, metric string, stats RepetitionStats) {
+	table.Append([]string{
+		metric,
+		fmt.Sprintf("%.3f", stats.Mean),
+		fmt.Sprintf("%.3f", stats.Median),
+		fmt.Sprintf("%.3f", stats.StdDev),
+		fmt.Sprintf("%.3f", stats.Min),
+		fmt.Sprintf("%.3f", stats.Max),
+		fmt.Sprintf("[%.3f, %.3f]", stats.CILower, stats.CIUpper),
+		fmt.Sprintf("%.1f", stats.CoefficientOfVariation),
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_getRepetitionStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   RepetitionStats
+	}{
+		{"empty", []float64{}, RepetitionStats{}},
+		{"single", []float64{5}, RepetitionStats{Mean: 5, Median: 5, Min: 5, Max: 5, CILower: 5, CIUpper: 5}},
+		{"even", []float64{4, 1, 3, 2}, RepetitionStats{Mean: 2.5, Median: 2.5, StdDev: 1.290994, Min: 1, Max: 4, CILower: 0.446028, CIUpper: 4.553972, CoefficientOfVariation: 51.639778}},
+		{"low-variance", []float64{100, 101, 99}, RepetitionStats{Mean: 100, Median: 100, StdDev: 1, Min: 99, Max: 101, CILower: 97.515662, CIUpper: 102.484338, CoefficientOfVariation: 1}},
+		{"high-variance", []float64{100, 150, 50}, RepetitionStats{Mean: 100, Median: 100, StdDev: 50, Min: 50, Max: 150, CILower: -24.216910, CIUpper: 224.216910, CoefficientOfVariation: 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getRepetitionStats(tt.values)
+			gotValues := []float64{got.Mean, got.Median, got.StdDev, got.Min, got.Max, got.CILower, got.CIUpper, got.CoefficientOfVariation}
+			wantValues := []float64{tt.want.Mean, tt.want.Median, tt.want.StdDev, tt.want.Min, tt.want.Max, tt.want.CILower, tt.want.CIUpper, tt.want.CoefficientOfVariation}
+			for i := range gotValues {
+				if math.Abs(gotValues[i]-wantValues[i]) > 1e-5 {
+					t.Errorf("getRepetitionStats() = %+v, want %+v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func Test_getRunDeviations(t *testing.T) {
+	metricNames := []string{"throughput", "p50"}
+	tests := []struct {
+		name         string
+		metricValues map[string][]float64
+		maxDeviation float64
+		want         []RepetitionRunDeviation
+	}{
+		{"stable", map[string][]float64{"throughput": {100, 101, 99}, "p50": {1, 1, 1}}, 10, []RepetitionRunDeviation{
+			{1, 0, "", false}, {2, 1, "throughput", false}, {3, 1, "throughput", false}}},
+		// only the repetition that deviates is flagged, on the metric it deviates the most
+		{"outlier-run", map[string][]float64{"throughput": {100, 100, 70}, "p50": {1, 1, 1.6}}, 10, []RepetitionRunDeviation{
+			{1, 0, "", false}, {2, 0, "", false}, {3, 60, "p50", true}}},
+		{"not-checked", map[string][]float64{"throughput": {100, 100, 70}, "p50": {0, 0, 0}}, 0, []RepetitionRunDeviation{
+			{1, 0, "", false}, {2, 0, "", false}, {3, 30, "throughput", false}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getRunDeviations(metricNames, tt.metricValues, 3, tt.maxDeviation)
+			for i := range got {
+				got[i].MaxDeviation = math.Round(got[i].MaxDeviation*1e6) / 1e6
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRunDeviations() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"strings"
+)
+
+// discoverReplicas returns the online replica endpoints ( host:port ) reported by
+// "INFO replication" on the given primary connection
+func discoverReplicas(conn redis.Conn) (replicas []string, err error) {
+	var info string
+	info, err = redis.String(conn.Do("INFO", "replication"))
+	if err != nil {
+		return
+	}
+	replicas = parseInfoReplicationReplicas(info)
+	return
+}
+
+// parseInfoReplicationReplicas extracts the online replicas from the "INFO replication" reply.
+// Each replica is reported in the format slave<N>:ip=<ip>,port=<port>,state=<state>,offset=<offset>,lag=<lag>
+func parseInfoReplicationReplicas(info string) []string {
+	replicas := make([]string, 0)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "slave") {
+			continue
+		}
+		sepPos := strings.Index(line, ":")
+		if sepPos < 0 || !strings.Contains(line[sepPos+1:], "=") {
+			continue
+		}
+		properties := map[string]string{}
+		for _, property := range strings.Split(line[sepPos+1:], ",") {
+			kv := strings.SplitN(property, "=", 2)
+			if len(kv) == 2 {
+				properties[kv[0]] = kv[1]
+			}
+		}
+		if properties["ip"] == "" || properties["port"] == "" {
+			continue
+		}
+		if state, found := properties["state"]; found && state != "online" {
+			continue
+		}
+		replicas = append(replicas, fmt.Sprintf("%s:%s", properties["ip"], properties["port"]))
+	}
+	return replicas
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseInfoReplicationReplicas(t *testing.T) {
+	tests := []struct {
+		name string
+		info string
+		want []string
+	}{
+		{"no-replicas", "# Replication\r\nrole:master\r\nconnected_slaves:0\r\nmaster_repl_offset:0\r\n", []string{}},
+		{"replica-role", "# Replication\r\nrole:slave\r\nmaster_host:127.0.0.1\r\nmaster_port:6379\r\nslave_repl_offset:100\r\nslave_priority:100\r\n", []string{}},
+		{"single-replica", "# Replication\r\nrole:master\r\nconnected_slaves:1\r\nslave0:ip=127.0.0.1,port=6380,state=online,offset=42,lag=0\r\n", []string{"127.0.0.1:6380"}},
+		{"multiple-replicas", "# Replication\r\nrole:master\r\nconnected_slaves:2\r\nslave0:ip=10.0.0.1,port=6380,state=online,offset=42,lag=0\r\nslave1:ip=10.0.0.2,port=6381,state=online,offset=42,lag=1\r\n", []string{"10.0.0.1:6380", "10.0.0.2:6381"}},
+		{"skip-syncing-replica", "# Replication\r\nrole:master\r\nconnected_slaves:2\r\nslave0:ip=10.0.0.1,port=6380,state=wait_bgsave,offset=0,lag=0\r\nslave1:ip=10.0.0.2,port=6381,state=online,offset=42,lag=1\r\n", []string{"10.0.0.2:6381"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInfoReplicationReplicas(tt.info); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseInfoReplicationReplicas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
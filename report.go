@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	reportChartWidth  = 900
+	reportChartHeight = 320
+	// space reserved for the axis labels and the legend
+	reportChartMarginLeft   = 70
+	reportChartMarginRight  = 180
+	reportChartMarginTop    = 30
+	reportChartMarginBottom = 45
+)
+
+var reportChartColors = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"}
+
+// reportDistributionPercentiles are the points of the latency distribution curves
+var reportDistributionPercentiles = []float64{0, 10, 25, 50, 75, 90, 95, 99, 99.5, 99.9, 99.95, 99.99, 99.995, 99.999}
+
+// reportTotalsColumns are the Totals counters of the resultset stats table, in order
+var reportTotalsColumns = []string{"IssuedQueries", "Errors", "NodesCreated", "NodesDeleted", "LabelsAdded", "PropertiesSet", "RelationshipsCreated", "RelationshipsDeleted"}
+
+// reportTick are the stats of a reporting period, as recorded on ClientRunTimeStats
+type reportTick struct {
+	Timestamp              int64                         `json:"-"`
+	Commands               uint64                        `json:"Commands"`
+	Errors                 uint64                        `json:"Errors"`
+	MessageRate            float64                       `json:"MessageRate"`
+	ClientLatencies        map[string]map[string]float64 `json:"ClientLatencies"`
+	GraphInternalLatencies map[string]map[string]float64 `json:"GraphInternalLatencies"`
+}
+
+// chartSeries is a named line of a chart
+type chartSeries struct {
+	name string
+	xs   []float64
+	ys   []float64
+}
+
+// chartTick is a labeled position on a chart axis
+type chartTick struct {
+	value float64
+	label string
+}
+
+type reportChart struct {
+	Title string
+	Chart template.HTML
+}
+
+type reportPage struct {
+	Title              string
+	Summary            [][]string
+	HasRunTimeStats    bool
+	Throughput         template.HTML
+	QueryLatencies     []reportChart
+	Distributions      []reportChart
+	Breakdown          template.HTML
+	ResultSetHeader    []string
+	ResultSetRows      [][]string
+	GeneratedTimestamp string
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; margin-top: 2em; border-bottom: 1px solid #ccc; }
+h3 { font-size: 1.1em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+svg { background: #fff; }
+.note { color: #777; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+{{range .Summary}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+<h2>Throughput over time</h2>
+{{if .HasRunTimeStats}}{{.Throughput}}{{else}}<p class="note">The results file does not include per tick stats.</p>{{end}}
+<h2>Latency over time</h2>
+{{if .HasRunTimeStats}}{{range .QueryLatencies}}<h3>{{.Title}}</h3>
+{{.Chart}}
+{{end}}{{else}}<p class="note">The results file does not include per tick stats.</p>{{end}}
+<h2>Latency distribution</h2>
+{{range .Distributions}}<h3>{{.Title}}</h3>
+{{.Chart}}
+{{else}}<p class="note">The results file does not include the latency histograms.</p>{{end}}
+<h2>Client vs RedisGraph internal latency breakdown</h2>
+{{.Breakdown}}
+<h2>Overall RedisGraph resultset stats</h2>
+<table>
+<tr>{{range .ResultSetHeader}}<th>{{.}}</th>{{end}}</tr>
+{{range .ResultSetRows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+<p class="note">Generated by redisgraph-benchmark-go on {{.GeneratedTimestamp}}</p>
+</body>
+</html>
+`))
+
+// getReportTicks decodes the per tick stats of the result, sorted by timestamp
+func getReportTicks(result *TestResult) (ticks []reportTick, err error) {
+	ticks = make([]reportTick, 0, len(result.ClientRunTimeStats))
+	for timestamp, stats := range result.ClientRunTimeStats {
+		var content []byte
+		content, err = json.Marshal(stats)
+		if err != nil {
+			return
+		}
+		tick := reportTick{}
+		if err = json.Unmarshal(content, &tick); err != nil {
+			err = fmt.Errorf("unable to decode the stats of tick %d: %v", timestamp, err)
+			return
+		}
+		tick.Timestamp = timestamp
+		ticks = append(ticks, tick)
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i].Timestamp < ticks[j].Timestamp })
+	return
+}
+
+// getPercentileFromKey returns the percentile of a latency map key, e.g. 99.99 for q99_99. It is the inverse of getPercentileKey
+func getPercentileFromKey(key string) (percentile float64, ok bool) {
+	for legacyPercentile, legacyKey := range legacyPercentileKeys {
+		if key == legacyKey {
+			return legacyPercentile, true
+		}
+	}
+	digits := strings.TrimPrefix(key, "q")
+	if digits == key || digits == "" {
+		return
+	}
+	var err error
+	percentile, err = strconv.ParseFloat(strings.Replace(digits, "_", ".", 1), 64)
+	ok = err == nil
+	return
+}
+
+// getReportLatencyKeys returns the average and percentile keys of the latency map, with the percentiles in increasing order
+func getReportLatencyKeys(latencies map[string]float64) []string {
+	percentileKeys := []string{}
+	for key := range latencies {
+		if _, ok := getPercentileFromKey(key); ok {
+			percentileKeys = append(percentileKeys, key)
+		}
+	}
+	sort.Slice(percentileKeys, func(i, j int) bool {
+		pi, _ := getPercentileFromKey(percentileKeys[i])
+		pj, _ := getPercentileFromKey(percentileKeys[j])
+		return pi < pj
+	})
+	keys := []string{}
+	if _, found := latencies["avg"]; found {
+		keys = append(keys, "avg")
+	}
+	return append(keys, percentileKeys...)
+}
+
+// getNiceStep returns a round axis step ( 1, 2 or 5 times a power of 10 ) splitting the range in about the given number of steps
+func getNiceStep(max float64, steps int) float64 {
+	if max <= 0 {
+		return 1
+	}
+	raw := max / float64(steps)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5} {
+		if raw <= m*magnitude {
+			return m * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// getLinearTicks returns the ticks from 0 up to ( at least ) max. There are always at least two ticks
+func getLinearTicks(max float64) (ticks []chartTick) {
+	step := getNiceStep(max, 5)
+	precision := 0
+	if step < 1 {
+		precision = int(math.Ceil(-math.Log10(step)))
+	}
+	for v := 0.0; ; v += step {
+		ticks = append(ticks, chartTick{v, strconv.FormatFloat(v, 'f', precision, 64)})
+		if v >= max && len(ticks) > 1 {
+			return
+		}
+	}
+}
+
+func getSeriesMax(series []chartSeries, values func(s chartSeries) []float64) (max float64) {
+	for _, s := range series {
+		for _, v := range values(s) {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) && v > max {
+				max = v
+			}
+		}
+	}
+	return
+}
+
+// renderLineChart returns an inline svg line chart. If xTicks is nil, the x axis is linear starting at 0
+func renderLineChart(xLabel string, yLabel string, series []chartSeries, xTicks []chartTick) template.HTML {
+	if xTicks == nil {
+		xTicks = getLinearTicks(getSeriesMax(series, func(s chartSeries) []float64 { return s.xs }))
+	}
+	yTicks := getLinearTicks(getSeriesMax(series, func(s chartSeries) []float64 { return s.ys }))
+	xMin, xMax := xTicks[0].value, xTicks[len(xTicks)-1].value
+	yMax := yTicks[len(yTicks)-1].value
+	plotWidth := float64(reportChartWidth - reportChartMarginLeft - reportChartMarginRight)
+	plotHeight := float64(reportChartHeight - reportChartMarginTop - reportChartMarginBottom)
+	toX := func(v float64) float64 {
+		if xMax == xMin {
+			return reportChartMarginLeft
+		}
+		return reportChartMarginLeft + (v-xMin)/(xMax-xMin)*plotWidth
+	}
+	toY := func(v float64) float64 {
+		return reportChartMarginTop + plotHeight - v/yMax*plotHeight
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="11">`, reportChartWidth, reportChartHeight)
+	for _, tick := range yTicks {
+		y := toY(tick.value)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#eee"/>`, reportChartMarginLeft, y, reportChartMarginLeft+plotWidth, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, reportChartMarginLeft-5, y+4, template.HTMLEscapeString(tick.label))
+	}
+	for _, tick := range xTicks {
+		x := toX(tick.value)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#eee"/>`, x, reportChartMarginTop, x, reportChartMarginTop+plotHeight)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, x, reportChartMarginTop+plotHeight+15, template.HTMLEscapeString(tick.label))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%.1f" fill="none" stroke="#999"/>`, reportChartMarginLeft, reportChartMarginTop, plotWidth, plotHeight)
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, reportChartMarginLeft+plotWidth/2, reportChartHeight-5, template.HTMLEscapeString(xLabel))
+	fmt.Fprintf(&b, `<text x="15" y="%.1f" text-anchor="middle" transform="rotate(-90 15 %.1f)">%s</text>`, reportChartMarginTop+plotHeight/2, reportChartMarginTop+plotHeight/2, template.HTMLEscapeString(yLabel))
+	for i, s := range series {
+		color := reportChartColors[i%len(reportChartColors)]
+		points := make([]string, 0, len(s.xs))
+		for j := range s.xs {
+			if math.IsNaN(s.ys[j]) || math.IsInf(s.ys[j], 0) {
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", toX(s.xs[j]), toY(s.ys[j])))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`, color, strings.Join(points, " "))
+		legendY := reportChartMarginTop + 10 + i*15
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="10" height="10" fill="%s"/>`, reportChartMarginLeft+plotWidth+10, legendY-9, color)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s</text>`, reportChartMarginLeft+plotWidth+25, legendY, template.HTMLEscapeString(getReportShortName(s.name)))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// getReportShortName truncates long queries, so that they fit on the chart legends
+func getReportShortName(name string) string {
+	if len(name) > 25 {
+		return name[:22] + "..."
+	}
+	return name
+}
+
+// renderBreakdownChart returns an inline svg with a horizontal bar per query, splitting the client latency
+// into the RedisGraph internal execution time and the remaining ( network, queueing and client ) overhead
+func renderBreakdownChart(queries []string, client map[string]float64, graphInternal map[string]float64) template.HTML {
+	max := 0.0
+	for _, query := range queries {
+		max = math.Max(max, math.Max(client[query], graphInternal[query]))
+	}
+	ticks := getLinearTicks(max)
+	xMax := ticks[len(ticks)-1].value
+	const barHeight, barGap, labelWidth = 20, 10, 200
+	plotWidth := float64(reportChartWidth - labelWidth - reportChartMarginRight)
+	height := reportChartMarginTop + len(queries)*(barHeight+barGap) + reportChartMarginBottom
+	toWidth := func(v float64) float64 {
+		if xMax == 0 {
+			return 0
+		}
+		return v / xMax * plotWidth
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="11">`, reportChartWidth, height)
+	plotBottom := height - reportChartMarginBottom
+	for _, tick := range ticks {
+		x := labelWidth + toWidth(tick.value)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#eee"/>`, x, reportChartMarginTop, x, plotBottom)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`, x, plotBottom+15, template.HTMLEscapeString(tick.label))
+	}
+	fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">average latency (ms)</text>`, labelWidth+plotWidth/2, height-5)
+	for i, query := range queries {
+		y := reportChartMarginTop + i*(barHeight+barGap)
+		internal := math.Min(graphInternal[query], client[query])
+		overhead := client[query] - internal
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-5, y+barHeight/2+4, template.HTMLEscapeString(getReportShortName(query)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"><title>RedisGraph internal %.3f ms</title></rect>`, labelWidth, y, toWidth(internal), barHeight, reportChartColors[0], internal)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"><title>Client overhead %.3f ms</title></rect>`, labelWidth+toWidth(internal), y, toWidth(overhead), barHeight, reportChartColors[1], overhead)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%.3f ms</text>`, labelWidth+toWidth(client[query])+5, y+barHeight/2+4, client[query])
+	}
+	legendX := float64(labelWidth) + plotWidth + 60
+	for i, name := range []string{"RedisGraph internal", "Client overhead"} {
+		legendY := reportChartMarginTop + 10 + i*15
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="10" height="10" fill="%s"/>`, legendX, legendY-9, reportChartColors[i])
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%s</text>`, legendX+15, legendY, name)
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// getDistributionSeries returns the latency distribution curve of each encoded histogram, with the x axis
+// as the number of nines of the percentile, so that the tail is not squeezed at the right edge
+func getDistributionSeries(queries []string, encodedHistograms map[string]string) (series []chartSeries, err error) {
+	series = []chartSeries{}
+	for _, query := range queries {
+		encoded, found := encodedHistograms[query]
+		if !found {
+			continue
+		}
+		var histogram *hdrhistogram.Histogram
+		histogram, err = hdrhistogram.Decode([]byte(encoded))
+		if err != nil {
+			err = fmt.Errorf("unable to decode the histogram of query '%s': %v", query, err)
+			return
+		}
+		s := chartSeries{name: query}
+		for _, percentile := range reportDistributionPercentiles {
+			s.xs = append(s.xs, -math.Log10(1-percentile/100.0))
+			s.ys = append(s.ys, float64(histogram.ValueAtQuantile(percentile))/1000.0)
+		}
+		series = append(series, s)
+	}
+	return
+}
+
+func getDistributionTicks() []chartTick {
+	return []chartTick{{0, "0%"}, {1, "90%"}, {2, "99%"}, {3, "99.9%"}, {4, "99.99%"}, {5, "99.999%"}}
+}
+
+// newReportPage builds the charts and tables of the report
+func newReportPage(result *TestResult) (page reportPage, err error) {
+	page.Title = "redisgraph-benchmark-go report"
+	if result.TestDescription != "" {
+		page.Title = fmt.Sprintf("%s - %s", page.Title, result.TestDescription)
+	}
+	page.GeneratedTimestamp = time.Now().UTC().Format(time.RFC3339)
+	queries := getAlignedQueries(result.OverallClientLatencies, result.Totals)
+	totalRate, _ := result.OverallQueryRates["Total"].(float64)
+	page.Summary = [][]string{
+		{"Start time", time.Unix(0, result.StartTime*int64(time.Millisecond)).UTC().Format(time.RFC3339)},
+		{"Duration", fmt.Sprintf("%.3f seconds", float64(result.DurationMillis)/1000.0)},
+		{"Clients", fmt.Sprintf("%d", result.Clients)},
+		{"Pipeline", fmt.Sprintf("%d", result.Pipeline)},
+		{"Issued commands", fmt.Sprintf("%d", result.IssuedCommands)},
+		{"Throughput", fmt.Sprintf("%.0f requests per second", totalRate)},
+		{"Benchmark fully run", strconv.FormatBool(result.BenchmarkFullyRun)},
+	}
+
+	var ticks []reportTick
+	ticks, err = getReportTicks(result)
+	if err != nil {
+		return
+	}
+	page.HasRunTimeStats = len(ticks) > 0
+	if page.HasRunTimeStats {
+		start := result.StartTime
+		if start == 0 || start > ticks[0].Timestamp {
+			start = ticks[0].Timestamp
+		}
+		throughput := chartSeries{name: "Total"}
+		for _, tick := range ticks {
+			throughput.xs = append(throughput.xs, float64(tick.Timestamp-start)/1000.0)
+			throughput.ys = append(throughput.ys, tick.MessageRate)
+		}
+		page.Throughput = renderLineChart("time (s)", "requests per second", []chartSeries{throughput}, nil)
+		for _, query := range queries {
+			keys := getReportLatencyKeys(ticks[0].ClientLatencies[query])
+			series := make([]chartSeries, len(keys))
+			for i, key := range keys {
+				series[i].name = key
+				for _, tick := range ticks {
+					series[i].xs = append(series[i].xs, float64(tick.Timestamp-start)/1000.0)
+					series[i].ys = append(series[i].ys, tick.ClientLatencies[query][key])
+				}
+			}
+			page.QueryLatencies = append(page.QueryLatencies, reportChart{Title: query, Chart: renderLineChart("time (s)", "client latency (ms)", series, nil)})
+		}
+	}
+
+	for _, distribution := range []struct {
+		title   string
+		encoded map[string]string
+	}{{"Client latency", result.EncodedClientHistograms}, {"RedisGraph internal execution time", result.EncodedGraphInternalHistograms}} {
+		var series []chartSeries
+		series, err = getDistributionSeries(queries, distribution.encoded)
+		if err != nil {
+			return
+		}
+		if len(series) > 0 {
+			page.Distributions = append(page.Distributions, reportChart{Title: distribution.title, Chart: renderLineChart("percentile", "latency (ms)", series, getDistributionTicks())})
+		}
+	}
+
+	client := map[string]float64{}
+	graphInternal := map[string]float64{}
+	for _, query := range queries {
+		client[query] = toFloat64Map(result.OverallClientLatencies[query])["avg"]
+		graphInternal[query] = toFloat64Map(result.OverallGraphInternalLatencies[query])["avg"]
+	}
+	page.Breakdown = renderBreakdownChart(queries, client, graphInternal)
+
+	page.ResultSetHeader = append([]string{"Query"}, reportTotalsColumns...)
+	for _, query := range queries {
+		totals := toFloat64Map(result.Totals[query])
+		row := []string{query}
+		for _, column := range reportTotalsColumns {
+			row = append(row, fmt.Sprintf("%.0f", totals[column]))
+		}
+		page.ResultSetRows = append(page.ResultSetRows, row)
+	}
+	return
+}
+
+func renderReport(writer io.Writer, result *TestResult) error {
+	page, err := newReportPage(result)
+	if err != nil {
+		return err
+	}
+	return reportTemplate.Execute(writer, page)
+}
+
+// runReport implements the report subcommand, returning the process exit code
+func runReport(args []string) int {
+	reportFlags := flag.NewFlagSet("report", flag.ExitOnError)
+	htmlOutFile := reportFlags.String("html-out-file", "benchmark-report.html", "Name of the html file to write the report to.")
+	reportFlags.Usage = func() {
+		fmt.Fprintf(reportFlags.Output(), "Usage of report: redisgraph-benchmark-go report [options] <results.json>\n")
+		reportFlags.PrintDefaults()
+	}
+	reportFlags.Parse(args)
+	files := reportFlags.Args()
+	if len(files) != 1 {
+		reportFlags.Usage()
+		log.Fatalf("You need to specify a single results file.")
+	}
+	result, err := loadTestResult(files[0])
+	if err != nil {
+		log.Fatalf("Unable to load results file %s. Error: %v", files[0], err)
+	}
+	f, err := os.Create(*htmlOutFile)
+	if err != nil {
+		log.Fatalf("Unable to create the report file %s. Error: %v", *htmlOutFile, err)
+	}
+	defer f.Close()
+	if err = renderReport(f, result); err != nil {
+		log.Printf("Unable to render the report. Error: %v\n", err)
+		return 1
+	}
+	log.Printf("Saved the report of %s to %s\n", files[0], *htmlOutFile)
+	return 0
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_getPercentileFromKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		want   float64
+		wantOk bool
+	}{
+		{"q50", 50, true},
+		{"q999", 99.9, true},
+		{"q99_9", 99.9, true},
+		{"q9_99", 9.99, true},
+		{"q99_99", 99.99, true},
+		{"q100", 100, true},
+		{"q0", 0, true},
+		{"avg", 0, false},
+		{"q", 0, false},
+		{"qabc", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, ok := getPercentileFromKey(tt.key)
+			if ok != tt.wantOk || (ok && got != tt.want) {
+				t.Errorf("getPercentileFromKey() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_getReportLatencyKeys(t *testing.T) {
+	latencies := map[string]float64{"q99_99": 1, "q999": 1, "min": 1, "q50": 1, "avg": 1, "max": 1, "q99": 1, "stddev": 1}
+	want := []string{"avg", "q50", "q99", "q999", "q99_99"}
+	if got := getReportLatencyKeys(latencies); !reflect.DeepEqual(got, want) {
+		t.Errorf("getReportLatencyKeys() = %v, want %v", got, want)
+	}
+}
+
+func Test_getLinearTicks(t *testing.T) {
+	tests := []struct {
+		name string
+		max  float64
+		want []string
+	}{
+		{"empty", 0, []string{"0", "1"}},
+		{"round", 1000, []string{"0", "200", "400", "600", "800", "1000"}},
+		{"not round", 7.3, []string{"0", "2", "4", "6", "8"}},
+		{"sub millisecond", 0.42, []string{"0.0", "0.1", "0.2", "0.3", "0.4", "0.5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, tick := range getLinearTicks(tt.max) {
+				got = append(got, tick.label)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getLinearTicks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newReportTestResult returns a result as read back from the json file, with two ticks
+func newReportTestResult(t *testing.T) *TestResult {
+	result := newTestResultWithHistograms(1000, 3000, map[string][]int64{"CREATE (n)": {1000, 2000}, "MATCH (n) RETURN n": {500}}, map[string]uint64{"CREATE (n)": 2, "MATCH (n) RETURN n": 1})
+	result.OverallClientLatencies = map[string]interface{}{"CREATE (n)": map[string]float64{"avg": 1.5}, "MATCH (n) RETURN n": map[string]float64{"avg": 0.5}, "Total": map[string]float64{"avg": 1.166}}
+	result.OverallGraphInternalLatencies = map[string]interface{}{"CREATE (n)": map[string]float64{"avg": 1.0}, "MATCH (n) RETURN n": map[string]float64{"avg": 0.25}, "Total": map[string]float64{"avg": 0.75}}
+	result.ClientRunTimeStats = map[int64]interface{}{}
+	for i, timestamp := range []int64{3000, 2000} {
+		latencies := map[string]interface{}{"CREATE (n)": map[string]float64{"avg": 1.5, "q50": 1, "q99": 2}, "Total": map[string]float64{"avg": 1.5, "q50": 1, "q99": 2}}
+		result.ClientRunTimeStats[timestamp] = map[string]interface{}{"Commands": uint64(i + 1), "Errors": uint64(0), "MessageRate": float64(i + 1), "ClientLatencies": latencies, "GraphInternalLatencies": latencies}
+	}
+	content, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	decoded := &TestResult{}
+	if err = json.Unmarshal(content, decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return decoded
+}
+
+func Test_getReportTicks(t *testing.T) {
+	ticks, err := getReportTicks(newReportTestResult(t))
+	if err != nil {
+		t.Fatalf("getReportTicks() error = %v", err)
+	}
+	if len(ticks) != 2 || ticks[0].Timestamp != 2000 || ticks[1].Timestamp != 3000 {
+		t.Fatalf("getReportTicks() = %v, want the 2000 and 3000 ticks, sorted", ticks)
+	}
+	if ticks[0].Commands != 2 || ticks[0].MessageRate != 2 || ticks[0].ClientLatencies["CREATE (n)"]["q99"] != 2 {
+		t.Errorf("getReportTicks() first tick = %+v, want 2 commands, a rate of 2 and a q99 of 2", ticks[0])
+	}
+}
+
+func Test_renderReport(t *testing.T) {
+	var b bytes.Buffer
+	if err := renderReport(&b, newReportTestResult(t)); err != nil {
+		t.Fatalf("renderReport() error = %v", err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"<h2>Throughput over time</h2>\n<svg",
+		"<h3>MATCH (n) RETURN n</h3>",
+		"<h3>Client latency</h3>",
+		"<h3>RedisGraph internal execution time</h3>",
+		"<title>Client overhead 0.500 ms</title>",
+		"<tr><td>CREATE (n)</td><td>2</td><td>1</td>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderReport() output does not include %q", want)
+		}
+	}
+	if strings.Contains(got, "NaN") {
+		t.Errorf("renderReport() output includes NaN coordinates")
+	}
+}
+
+func Test_renderReport_withoutRunTimeStats(t *testing.T) {
+	result := newReportTestResult(t)
+	result.ClientRunTimeStats = nil
+	var b bytes.Buffer
+	if err := renderReport(&b, result); err != nil {
+		t.Fatalf("renderReport() error = %v", err)
+	}
+	if !strings.Contains(b.String(), "does not include per tick stats") {
+		t.Errorf("renderReport() output does not mention the missing per tick stats")
+	}
+}
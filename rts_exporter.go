@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	redistimeseries "github.com/RedisTimeSeries/redistimeseries-go"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const rtsExporterRetryBackoff = 100 * time.Millisecond
+
+// rtsBatch holds the samples of a single reporting period
+type rtsBatch struct {
+	timestamp int64
+	samples   []tickSample
+}
+
+// rtsExporter pushes the samples to RedisTimeSeries on its own go-routine, so that a slow exporter does not delay the reporting periods.
+// Each batch is sent via a single TS.MADD. Series are created with their labels the first time they are seen
+type rtsExporter struct {
+	client       *redistimeseries.Client
+	queue        chan rtsBatch
+	maxRetries   int
+	retryBackoff time.Duration
+	createdKeys  map[string]bool
+	wg           sync.WaitGroup
+	// sample counters. dropped samples did not fit on the queue, failed samples were rejected or exhausted the retries
+	exported uint64
+	dropped  uint64
+	failed   uint64
+}
+
+func newRtsExporter(client *redistimeseries.Client, queueSize int, maxRetries int) *rtsExporter {
+	e := &rtsExporter{
+		client:       client,
+		queue:        make(chan rtsBatch, queueSize),
+		maxRetries:   maxRetries,
+		retryBackoff: rtsExporterRetryBackoff,
+		createdKeys:  map[string]bool{},
+	}
+	e.wg.Add(1)
+	go e.processQueue()
+	return e
+}
+
+// enqueue never blocks. If the queue is full the batch is dropped
+func (e *rtsExporter) enqueue(samples []tickSample, now time.Time) {
+	select {
+	case e.queue <- rtsBatch{timestamp: now.UTC().UnixMilli(), samples: samples}:
+	default:
+		atomic.AddUint64(&e.dropped, uint64(len(samples)))
+	}
+}
+
+func (e *rtsExporter) exportTick(samples []tickSample, now time.Time) {
+	e.enqueue(samples, now)
+}
+
+func (e *rtsExporter) exportResult(result *TestResult, samples []tickSample, now time.Time) {
+	e.enqueue(samples, now)
+}
+
+// close waits for the queued batches to be exported
+func (e *rtsExporter) close() error {
+	close(e.queue)
+	e.wg.Wait()
+	log.Println(e.summary())
+	return nil
+}
+
+func (e *rtsExporter) processQueue() {
+	defer e.wg.Done()
+	for batch := range e.queue {
+		failed, err := e.exportWithRetries(batch)
+		if err != nil {
+			log.Printf("Unable to export %d samples to RedisTimeSeries. Error: %v\n", len(batch.samples), err)
+			failed = len(batch.samples)
+		}
+		atomic.AddUint64(&e.failed, uint64(failed))
+		atomic.AddUint64(&e.exported, uint64(len(batch.samples)-failed))
+	}
+}
+
+// exportWithRetries retries the batch on connection errors, with an exponential backoff.
+// Batches rejected by RedisTimeSeries ( e.g. a key holding another type ) are not retried, given they would be rejected again
+func (e *rtsExporter) exportWithRetries(batch rtsBatch) (failed int, err error) {
+	backoff := e.retryBackoff
+	for attempt := 0; ; attempt++ {
+		failed, err = e.export(batch)
+		if !isRtsConnectionError(err) || attempt >= e.maxRetries {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// export returns the number of samples rejected by RedisTimeSeries ( e.g. duplicate timestamps ), or an error if the batch was not exported
+func (e *rtsExporter) export(batch rtsBatch) (failed int, err error) {
+	rtsSamples := make([]redistimeseries.Sample, 0, len(batch.samples))
+	for _, dp := range batch.samples {
+		if !e.createdKeys[dp.key] {
+			if err = e.createKey(dp); err != nil {
+				return
+			}
+			e.createdKeys[dp.key] = true
+		}
+		rtsSamples = append(rtsSamples, redistimeseries.Sample{Key: dp.key, DataPoint: redistimeseries.DataPoint{Timestamp: batch.timestamp, Value: dp.value}})
+	}
+	var replies []interface{}
+	replies, err = e.client.MultiAdd(rtsSamples...)
+	if err != nil {
+		return
+	}
+	failed = countRejectedSamples(replies)
+	return
+}
+
+func (e *rtsExporter) createKey(dp tickSample) error {
+	opts := redistimeseries.DefaultCreateOptions
+	opts.Labels = dp.labels
+	err := e.client.CreateKeyWithOptions(dp.key, opts)
+	// series from previous runs with the same run name are reused, with the labels of the current run
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "already exists") {
+		err = e.client.AlterKeyWithOptions(dp.key, opts)
+	}
+	return err
+}
+
+// isRtsConnectionError is true for the errors worth retrying: network errors ( including timeouts ) and closed connections.
+// Error replies of RedisTimeSeries are not
+func isRtsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// countRejectedSamples returns the number of per sample errors of a TS.MADD reply
+func countRejectedSamples(replies []interface{}) (rejected int) {
+	for _, reply := range replies {
+		if _, isError := reply.(redis.Error); isError {
+			rejected++
+		}
+	}
+	return
+}
+
+// rtsExporterStats are the sample counters of the exporter, reported on the json results
+type rtsExporterStats struct {
+	Exported uint64 `json:"Exported"`
+	Dropped  uint64 `json:"Dropped"`
+	Failed   uint64 `json:"Failed"`
+}
+
+func (e *rtsExporter) stats() rtsExporterStats {
+	return rtsExporterStats{Exported: atomic.LoadUint64(&e.exported), Dropped: atomic.LoadUint64(&e.dropped), Failed: atomic.LoadUint64(&e.failed)}
+}
+
+func (e *rtsExporter) summary() string {
+	stats := e.stats()
+	return fmt.Sprintf("RedisTimeSeries exporter: %d samples exported, %d dropped ( queue full ), %d failed", stats.Exported, stats.Dropped, stats.Failed)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_countRejectedSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []interface{}
+		want    int
+	}{
+		{"all stored", []interface{}{int64(1000), int64(1000)}, 0},
+		{"duplicate timestamp", []interface{}{int64(1000), redis.Error("ERR TSDB: duplicate sample")}, 1},
+		{"empty", []interface{}{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countRejectedSamples(tt.replies); got != tt.want {
+				t.Errorf("countRejectedSamples() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rtsExporter_enqueue(t *testing.T) {
+	// no go-routine is consuming the queue, so that it fills up
+	e := &rtsExporter{queue: make(chan rtsBatch, 2)}
+	samples := []tickSample{{key: "a"}, {key: "b"}, {key: "c"}}
+	for i := 0; i < 4; i++ {
+		e.enqueue(samples, time.Unix(1, 500000000))
+	}
+	if got := len(e.queue); got != 2 {
+		t.Errorf("enqueue() queued %d batches, want 2", got)
+	}
+	if stats := e.stats(); stats != (rtsExporterStats{Dropped: 6}) {
+		t.Errorf("enqueue() stats = %+v, want 6 dropped samples", stats)
+	}
+	if batch := <-e.queue; batch.timestamp != 1500 {
+		t.Errorf("enqueue() batch timestamp = %d, want 1500", batch.timestamp)
+	}
+}
+
+func Test_isRtsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"timeout", timeoutError{}, true},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}, true},
+		{"closed connection", io.EOF, true},
+		{"wrong type", redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value"), false},
+		{"duplicate sample", redis.Error("ERR TSDB: duplicate sample"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRtsConnectionError(tt.err); got != tt.want {
+				t.Errorf("isRtsConnectionError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
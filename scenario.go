@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+)
+
+// scenarioQuery is a single query of a scenario phase. When no ratio is specified in any of
+// the phase queries, all of them are issued with the same ratio
+type scenarioQuery struct {
+	Query    string  `json:"query"`
+	Ratio    float64 `json:"ratio"`
+	ReadOnly bool    `json:"read-only"`
+}
+
+// scenarioPhase describes one of the ordered phases of a scenario. Unset load settings
+// default to the ones specified via the command line parameters
+type scenarioPhase struct {
+	Name           string          `json:"name"`
+	Queries        []scenarioQuery `json:"queries"`
+	Clients        uint64          `json:"clients"`
+	Requests       uint64          `json:"requests"`
+	Duration       string          `json:"duration"`
+	Rps            *int64          `json:"rps"` // an explicit 0 means no limit, instead of the command line rps
+	Pipeline       uint64          `json:"pipeline"`
+	WarmupRequests uint64          `json:"warmup-requests"`
+	WarmupTime     string          `json:"warmup-time"`
+	// issue GRAPH.DELETE once all the phase queries ( if any ) have been issued
+	DeleteGraph bool `json:"delete-graph"`
+	// service level objectives of the phase, with the same format as the -slo parameter.
+	// Contrary to the load settings, they do not default to the command line ones
+	SLOs []string `json:"slos"`
+}
+
+type scenario struct {
+	Phases []scenarioPhase `json:"phases"`
+}
+
+type PhaseResult struct {
+	Name         string      `json:"Name"`
+	GraphDeleted bool        `json:"GraphDeleted"`
+	Result       *TestResult `json:"Result"`
+}
+
+func loadScenario(scenarioFile string) (s scenario, err error) {
+	var content []byte
+	content, err = ioutil.ReadFile(scenarioFile)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(content, &s)
+	if err != nil {
+		return
+	}
+	if len(s.Phases) == 0 {
+		err = fmt.Errorf("the scenario file %s has no phases", scenarioFile)
+	}
+	return
+}
+
+// getWorkload returns the benchmark workload of the phase, using the defaults for any unset load setting
+func (p scenarioPhase) getWorkload(defaults benchmarkWorkload) (w benchmarkWorkload, err error) {
+	w = defaults
+	w.name = p.Name
+	w.queries = make([]string, len(p.Queries))
+	w.queryIsReadOnly = make([]bool, len(p.Queries))
+	w.cmdRates = make([]float64, len(p.Queries))
+	ratiosSpecified := false
+	for i, query := range p.Queries {
+		w.queries[i] = query.Query
+		w.queryIsReadOnly[i] = query.ReadOnly
+		w.cmdRates[i] = query.Ratio
+		ratiosSpecified = ratiosSpecified || query.Ratio != 0
+	}
+	if !ratiosSpecified {
+		for i := range w.cmdRates {
+			w.cmdRates[i] = 1.0 / float64(len(w.cmdRates))
+		}
+	}
+	if len(w.queries) > 0 {
+		w.cdf = getCommandsCDF(w.cmdRates)
+	}
+	w.slos, err = parseSLOs(p.SLOs, w.queries)
+	if err != nil {
+		return
+	}
+	if p.Clients > 0 {
+		w.clients = p.Clients
+	}
+	if p.Rps != nil {
+		if *p.Rps < 0 {
+			err = fmt.Errorf("rps can not be negative")
+			return
+		}
+		w.rps = *p.Rps
+	}
+	if p.Pipeline > 0 {
+		w.pipeline = p.Pipeline
+	}
+	if p.Requests > 0 && p.Duration != "" {
+		err = fmt.Errorf("only one of requests or duration can be specified")
+		return
+	}
+	if p.Requests > 0 {
+		w.numberRequests = p.Requests
+	}
+	w.duration = 0
+	if p.Duration != "" {
+		w.duration, err = time.ParseDuration(p.Duration)
+		if err != nil {
+			return
+		}
+		w.numberRequests = 0
+	}
+	w.warmupRequests = p.WarmupRequests
+	w.warmupTime = 0
+	if p.WarmupTime != "" {
+		w.warmupTime, err = time.ParseDuration(p.WarmupTime)
+	}
+	return
+}
+
+// runScenario runs each of the scenario phases in order, stopping if any of them is interrupted
+func runScenario(runner *benchmarkRunner, s scenario, defaults benchmarkWorkload) (phaseResults []PhaseResult, completed bool) {
+	phaseResults = make([]PhaseResult, 0, len(s.Phases))
+	completed = true
+	for i, phase := range s.Phases {
+		w, err := phase.getWorkload(defaults)
+		if err != nil {
+			log.Fatalf("Invalid settings on phase %d ( %s ). Error: %v", i+1, phase.Name, err)
+		}
+		log.Printf("Running phase %d/%d: %s\n", i+1, len(s.Phases), phase.Name)
+		phaseResult := PhaseResult{Name: phase.Name}
+		if len(w.queries) > 0 {
+			phaseResult.Result, completed = runner.run(w)
+		}
+		if completed && phase.DeleteGraph {
+			log.Printf("Deleting graph %s\n", runner.graphKey)
+			if err = runner.deleteGraph(); err != nil {
+				log.Printf("Unable to delete graph %s. Error: %v\n", runner.graphKey, err)
+			} else {
+				phaseResult.GraphDeleted = true
+			}
+		}
+		phaseResults = append(phaseResults, phaseResult)
+		if !completed {
+			log.Printf("Phase %s was interrupted. Skipping the remaining phases\n", phase.Name)
+			break
+		}
+	}
+	return
+}
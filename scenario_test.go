@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_scenarioPhase_getWorkload(t *testing.T) {
+	defaults := benchmarkWorkload{clients: 50, numberRequests: 1000000, rps: 0, pipeline: 1}
+	tests := []struct {
+		name               string
+		phase              scenarioPhase
+		wantCmdRates       []float64
+		wantClients        uint64
+		wantNumberRequests uint64
+		wantDuration       time.Duration
+		wantErr            bool
+	}{
+		{"defaults", scenarioPhase{Queries: []scenarioQuery{{Query: "CREATE (n)"}}}, []float64{1.0}, 50, 1000000, 0, false},
+		{"equal-ratios", scenarioPhase{Clients: 1, Requests: 1, Queries: []scenarioQuery{{Query: "CREATE (n)"}, {Query: "MATCH (n) RETURN n", ReadOnly: true}}}, []float64{0.5, 0.5}, 1, 1, 0, false},
+		{"explicit-ratios", scenarioPhase{Duration: "10s", Queries: []scenarioQuery{{Query: "CREATE (n)", Ratio: 0.2}, {Query: "MATCH (n) RETURN n", Ratio: 0.8, ReadOnly: true}}}, []float64{0.2, 0.8}, 50, 0, 10 * time.Second, false},
+		{"only-delete-graph", scenarioPhase{DeleteGraph: true}, []float64{}, 50, 1000000, 0, false},
+		{"requests-and-duration", scenarioPhase{Requests: 10, Duration: "10s", Queries: []scenarioQuery{{Query: "CREATE (n)"}}}, nil, 0, 0, 0, true},
+		{"invalid-duration", scenarioPhase{Duration: "10 seconds", Queries: []scenarioQuery{{Query: "CREATE (n)"}}}, nil, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.phase.getWorkload(defaults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getWorkload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.cmdRates, tt.wantCmdRates) {
+				t.Errorf("getWorkload() cmdRates = %v, want %v", got.cmdRates, tt.wantCmdRates)
+			}
+			if got.clients != tt.wantClients || got.numberRequests != tt.wantNumberRequests || got.duration != tt.wantDuration {
+				t.Errorf("getWorkload() clients = %d, numberRequests = %d, duration = %v, want %d, %d, %v", got.clients, got.numberRequests, got.duration, tt.wantClients, tt.wantNumberRequests, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func Test_scenarioPhase_getWorkload_rps(t *testing.T) {
+	defaults := benchmarkWorkload{clients: 50, numberRequests: 1000000, rps: 1000, pipeline: 1}
+	tests := []struct {
+		name    string
+		phase   string
+		wantRps int64
+		wantErr bool
+	}{
+		{"unset", `{"queries": [{"query": "CREATE (n)"}]}`, 1000, false},
+		// an explicit 0 overrides the command line rps back to no limit
+		{"unlimited", `{"rps": 0, "queries": [{"query": "CREATE (n)"}]}`, 0, false},
+		{"explicit", `{"rps": 500, "queries": [{"query": "CREATE (n)"}]}`, 500, false},
+		{"negative", `{"rps": -1, "queries": [{"query": "CREATE (n)"}]}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var phase scenarioPhase
+			if err := json.Unmarshal([]byte(tt.phase), &phase); err != nil {
+				t.Fatalf("unable to unmarshal the phase. Error: %v", err)
+			}
+			got, err := phase.getWorkload(defaults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getWorkload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got.rps != tt.wantRps {
+				t.Errorf("getWorkload() rps = %d, want %d", got.rps, tt.wantRps)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	rg "github.com/RedisGraph/redisgraph-go"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FailoverEvent struct {
+	Timestamp      int64  `json:"Timestamp"`
+	PreviousMaster string `json:"PreviousMaster"`
+	NewMaster      string `json:"NewMaster"`
+}
+
+// sentinelResolver keeps track of the current master ( and its replicas ) of a Sentinel monitored deployment.
+// Each time the master changes the epoch is incremented, so that the connections can detect they need to re-connect.
+type sentinelResolver struct {
+	sentinels      []string
+	masterName     string
+	mutex          sync.RWMutex
+	master         string
+	epoch          uint64
+	failoverEvents []FailoverEvent
+}
+
+func newSentinelResolver(sentinels []string, masterName string) (resolver *sentinelResolver, err error) {
+	resolver = &sentinelResolver{sentinels: sentinels, masterName: masterName}
+	resolver.master, err = resolver.resolveMaster()
+	return
+}
+
+// doOnSentinels issues the command on each of the sentinels, up until one of them replies successfully
+func (s *sentinelResolver) doOnSentinels(commandName string, args ...interface{}) (reply interface{}, err error) {
+	err = fmt.Errorf("no sentinel endpoints specified")
+	for _, sentinel := range s.sentinels {
+		var conn redis.Conn
+		conn, err = redis.Dial("tcp", sentinel, redis.DialConnectTimeout(time.Second), redis.DialReadTimeout(time.Second))
+		if err != nil {
+			continue
+		}
+		reply, err = conn.Do(commandName, args...)
+		conn.Close()
+		if err == nil {
+			return
+		}
+	}
+	return
+}
+
+// resolveMaster returns the current master address by issuing "SENTINEL get-master-addr-by-name"
+func (s *sentinelResolver) resolveMaster() (master string, err error) {
+	var addr []string
+	addr, err = redis.Strings(s.doOnSentinels("SENTINEL", "get-master-addr-by-name", s.masterName))
+	if err != nil {
+		return
+	}
+	if len(addr) != 2 {
+		err = fmt.Errorf("no master named '%s' is being monitored by the sentinels %v", s.masterName, s.sentinels)
+		return
+	}
+	master = fmt.Sprintf("%s:%s", addr[0], addr[1])
+	return
+}
+
+// resolveReplicas returns the healthy replicas of the master by issuing "SENTINEL replicas"
+func (s *sentinelResolver) resolveReplicas() (replicas []string, err error) {
+	var values []interface{}
+	values, err = redis.Values(s.doOnSentinels("SENTINEL", "replicas", s.masterName))
+	if err != nil {
+		return
+	}
+	return parseSentinelReplicas(values)
+}
+
+// parseSentinelReplicas extracts the healthy replicas from the "SENTINEL replicas" reply.
+// Each replica is reported as a flat list of field/value pairs, including the ip, port and flags fields
+func parseSentinelReplicas(values []interface{}) (replicas []string, err error) {
+	replicas = make([]string, 0)
+	for _, rawReplica := range values {
+		var replicaInfo map[string]string
+		replicaInfo, err = redis.StringMap(rawReplica, nil)
+		if err != nil {
+			return
+		}
+		flags := replicaInfo["flags"]
+		if strings.Contains(flags, "s_down") || strings.Contains(flags, "o_down") || strings.Contains(flags, "disconnected") {
+			continue
+		}
+		replicas = append(replicas, fmt.Sprintf("%s:%s", replicaInfo["ip"], replicaInfo["port"]))
+	}
+	return
+}
+
+// Master returns the current master address and the epoch in which it was resolved
+func (s *sentinelResolver) Master() (string, uint64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.master, s.epoch
+}
+
+func (s *sentinelResolver) FailoverEvents() []FailoverEvent {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]FailoverEvent{}, s.failoverEvents...)
+}
+
+// watchFailovers periodically re-resolves the master, recording a failover event each time it changes
+func (s *sentinelResolver) watchFailovers(interval time.Duration, stop chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			master, err := s.resolveMaster()
+			if err != nil {
+				log.Printf("Unable to re-resolve the master via sentinel. Error: %v\n", err)
+				continue
+			}
+			s.mutex.Lock()
+			if master != s.master {
+				event := FailoverEvent{Timestamp: time.Now().UTC().UnixNano() / 1000000, PreviousMaster: s.master, NewMaster: master}
+				log.Printf("Detected failover of master '%s' from %s to %s\n", s.masterName, s.master, master)
+				s.failoverEvents = append(s.failoverEvents, event)
+				s.master = master
+				s.epoch++
+			}
+			s.mutex.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// waitForFailover blocks up until a master newer than the given epoch is resolved, or the timeout is reached
+func (s *sentinelResolver) waitForFailover(epoch uint64, timeout time.Duration) (master string, newEpoch uint64, err error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		master, newEpoch = s.Master()
+		if newEpoch != epoch {
+			return
+		}
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("no failover of master '%s' was detected in %v", s.masterName, timeout)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// sentinelConn is a connection to the master ( or to one of its replicas ) that transparently follows it on failover.
+// A command failing due to the master being gone ( or demoted ) is retried once on the new master. Pipelined commands
+// that were sent but whose replies were not read yet are sent again on the new connection.
+type sentinelConn struct {
+	resolver        *sentinelResolver
+	dial            func(addr string) (redis.Conn, error)
+	failoverTimeout time.Duration
+	// position of the replica on the "SENTINEL replicas" reply, or -1 for the master
+	replicaPos int
+	conn       redis.Conn
+	epoch      uint64
+	// commands sent and not yet received
+	pending []sentinelCommand
+}
+
+type sentinelCommand struct {
+	commandName string
+	args        []interface{}
+}
+
+func newSentinelConn(resolver *sentinelResolver, replicaPos int, failoverTimeout time.Duration, dial func(addr string) (redis.Conn, error)) (*sentinelConn, error) {
+	c := &sentinelConn{resolver: resolver, dial: dial, failoverTimeout: failoverTimeout, replicaPos: replicaPos}
+	var addr string
+	var err error
+	addr, c.epoch, err = c.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if c.conn, err = dial(addr); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// resolve returns the current address of the master or replica, and the epoch of the master.
+// If there are no healthy replicas the read-only queries fall back to the master
+func (c *sentinelConn) resolve() (addr string, epoch uint64, err error) {
+	addr, epoch = c.resolver.Master()
+	if c.replicaPos < 0 {
+		return
+	}
+	var replicas []string
+	replicas, err = c.resolver.resolveReplicas()
+	if err != nil || len(replicas) == 0 {
+		return
+	}
+	addr = replicas[c.replicaPos%len(replicas)]
+	return
+}
+
+// reconnect replaces the connection after it failed with cause. Unless the master was demoted, the current master
+// ( or replica ) is dialed again first, given the connection might have been dropped without a failover.
+// Only when that fails the master connections wait for the failover
+func (c *sentinelConn) reconnect(cause error) (err error) {
+	var conn redis.Conn
+	var addr string
+	var epoch uint64
+	addr, epoch, err = c.resolve()
+	if err == nil && (epoch != c.epoch || !isReadOnlyError(cause)) {
+		conn, err = c.dial(addr)
+	}
+	if conn == nil && epoch == c.epoch && c.replicaPos < 0 {
+		if _, _, err = c.resolver.waitForFailover(c.epoch, c.failoverTimeout); err != nil {
+			return
+		}
+		if addr, epoch, err = c.resolve(); err == nil {
+			conn, err = c.dial(addr)
+		}
+	}
+	if conn == nil {
+		if err == nil {
+			err = cause
+		}
+		return
+	}
+	c.conn.Close()
+	c.conn, c.epoch = conn, epoch
+	return
+}
+
+// followFailover moves an idle connection to the new master ( or replicas ) once the failover is detected, so that
+// the queries are not sent to a demoted master or to the promoted replica
+func (c *sentinelConn) followFailover() {
+	if len(c.pending) > 0 {
+		return
+	}
+	if _, epoch := c.resolver.Master(); epoch == c.epoch {
+		return
+	}
+	if err := c.reconnect(nil); err != nil {
+		log.Printf("Unable to follow the failover of master '%s'. Error: %v\n", c.resolver.masterName, err)
+	}
+}
+
+// resend sends again the pending commands on the new connection
+func (c *sentinelConn) resend() error {
+	for _, cmd := range c.pending {
+		if err := c.conn.Send(cmd.commandName, cmd.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *sentinelConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sentinelConn) Err() error {
+	return c.conn.Err()
+}
+
+func (c *sentinelConn) Send(commandName string, args ...interface{}) (err error) {
+	c.followFailover()
+	c.pending = append(c.pending, sentinelCommand{commandName, args})
+	err = c.conn.Send(commandName, args...)
+	if err != nil && isFailoverError(err) && c.reconnect(err) == nil {
+		err = c.resend()
+	}
+	if err != nil {
+		// its reply will never be read
+		c.pending = c.pending[:len(c.pending)-1]
+	}
+	return
+}
+
+func (c *sentinelConn) Flush() (err error) {
+	err = c.conn.Flush()
+	if err != nil && isFailoverError(err) && c.reconnect(err) == nil {
+		if err = c.resend(); err == nil {
+			err = c.conn.Flush()
+		}
+	}
+	if err != nil {
+		// none of the replies will be read
+		c.pending = nil
+	}
+	return
+}
+
+func (c *sentinelConn) Receive() (reply interface{}, err error) {
+	reply, err = c.conn.Receive()
+	if err != nil && len(c.pending) > 0 && isFailoverError(err) && c.reconnect(err) == nil {
+		if err = c.resend(); err == nil {
+			err = c.conn.Flush()
+		}
+		if err == nil {
+			reply, err = c.conn.Receive()
+		}
+	}
+	if len(c.pending) > 0 {
+		c.pending = c.pending[1:]
+	}
+	return
+}
+
+func (c *sentinelConn) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
+	c.followFailover()
+	reply, err = c.conn.Do(commandName, args...)
+	// Do reads the replies of all pending commands
+	c.pending = nil
+	if err == nil || !isFailoverError(err) {
+		return
+	}
+	if c.reconnect(err) != nil {
+		return
+	}
+	return c.conn.Do(commandName, args...)
+}
+
+// isFailoverError returns true for connection level errors and for writes against a demoted master.
+// Timeouts are not, given they are usually due to slow queries
+func isFailoverError(err error) bool {
+	switch e := err.(type) {
+	case redis.Error:
+		return isReadOnlyError(e)
+	case net.Error:
+		return !e.Timeout()
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isReadOnlyError(err error) bool {
+	redisErr, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(string(redisErr), "READONLY")
+}
+
+// getSentinelConn opens a connection to the master, or to one of its replicas if replicaPos is not negative
+func getSentinelConn(graphName string, resolver *sentinelResolver, replicaPos int, failoverTimeout time.Duration, password string, tlsCaCertFile string) (graph rg.Graph, conn redis.Conn) {
+	conn, err := newSentinelConn(resolver, replicaPos, failoverTimeout, func(addr string) (redis.Conn, error) {
+		return dialStandalone("tcp", addr, password, tlsCaCertFile)
+	})
+	if err != nil {
+		log.Fatalf("Error preparing for benchmark, while creating new connection to the master. error = %v", err)
+	}
+	return rg.GraphNew(graphName, conn), conn
+}
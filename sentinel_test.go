@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func sentinelReplicaReply(fields ...string) []interface{} {
+	reply := make([]interface{}, len(fields))
+	for i, field := range fields {
+		reply[i] = []byte(field)
+	}
+	return reply
+}
+
+func Test_parseSentinelReplicas(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"no-replicas", []interface{}{}, []string{}, false},
+		{"single-replica", []interface{}{sentinelReplicaReply("name", "127.0.0.1:6380", "ip", "127.0.0.1", "port", "6380", "flags", "slave")}, []string{"127.0.0.1:6380"}, false},
+		{"skip-down-replicas", []interface{}{
+			sentinelReplicaReply("name", "10.0.0.1:6380", "ip", "10.0.0.1", "port", "6380", "flags", "s_down,slave"),
+			sentinelReplicaReply("name", "10.0.0.2:6380", "ip", "10.0.0.2", "port", "6380", "flags", "slave,disconnected"),
+			sentinelReplicaReply("name", "10.0.0.3:6380", "ip", "10.0.0.3", "port", "6380", "flags", "slave"),
+		}, []string{"10.0.0.3:6380"}, false},
+		{"malformed-reply", []interface{}{sentinelReplicaReply("name", "10.0.0.1:6380", "ip")}, []string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSentinelReplicas(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSentinelReplicas() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSentinelReplicas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeConn replies to each command with the address of the server and the command name, or fails with err
+type fakeConn struct {
+	addr   string
+	err    error
+	sent   []string
+	queued []string
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return c.err }
+
+func (c *fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.sent = append(c.sent, commandName)
+	return c.addr + " " + commandName, nil
+}
+
+func (c *fakeConn) Send(commandName string, args ...interface{}) error {
+	c.sent = append(c.sent, commandName)
+	c.queued = append(c.queued, commandName)
+	return nil
+}
+
+func (c *fakeConn) Flush() error { return c.err }
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	commandName := c.queued[0]
+	c.queued = c.queued[1:]
+	return c.addr + " " + commandName, nil
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// newFailoverTestConn returns a master connection to 10.0.0.1:6379 and the fake connections dialed by it.
+// The old master refuses any new connection
+func newFailoverTestConn(t *testing.T) (c *sentinelConn, resolver *sentinelResolver, dialed map[string][]*fakeConn) {
+	resolver = &sentinelResolver{masterName: "mymaster", master: "10.0.0.1:6379"}
+	dialed = map[string][]*fakeConn{}
+	dial := func(addr string) (redis.Conn, error) {
+		if addr == "10.0.0.1:6379" && len(dialed[addr]) > 0 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		conn := &fakeConn{addr: addr}
+		dialed[addr] = append(dialed[addr], conn)
+		return conn, nil
+	}
+	c, err := newSentinelConn(resolver, -1, time.Second, dial)
+	if err != nil {
+		t.Fatalf("newSentinelConn() error = %v", err)
+	}
+	return
+}
+
+func promote(resolver *sentinelResolver, master string) {
+	resolver.mutex.Lock()
+	resolver.master = master
+	resolver.epoch++
+	resolver.mutex.Unlock()
+}
+
+func Test_sentinelConn_Do(t *testing.T) {
+	c, resolver, dialed := newFailoverTestConn(t)
+	dialed["10.0.0.1:6379"][0].err = io.EOF
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		promote(resolver, "10.0.0.2:6379")
+	}()
+	reply, err := c.Do("GRAPH.QUERY")
+	if err != nil || reply != "10.0.0.2:6379 GRAPH.QUERY" {
+		t.Fatalf("Do() = %v, %v, want the reply of the new master", reply, err)
+	}
+	if c.epoch != 1 {
+		t.Errorf("Do() epoch = %d, want 1", c.epoch)
+	}
+}
+
+func Test_sentinelConn_Do_timeout(t *testing.T) {
+	c, _, dialed := newFailoverTestConn(t)
+	dialed["10.0.0.1:6379"][0].err = timeoutError{}
+	start := time.Now()
+	if _, err := c.Do("GRAPH.QUERY"); err != (timeoutError{}) {
+		t.Errorf("Do() error = %v, want the timeout error", err)
+	}
+	if took := time.Since(start); took > 500*time.Millisecond || len(dialed) != 1 {
+		t.Errorf("Do() waited %v and dialed %v on a timeout, want no failover", took, dialed)
+	}
+}
+
+func Test_sentinelConn_Do_followsDetectedFailover(t *testing.T) {
+	c, resolver, _ := newFailoverTestConn(t)
+	promote(resolver, "10.0.0.2:6379")
+	if reply, err := c.Do("GRAPH.RO_QUERY"); err != nil || reply != "10.0.0.2:6379 GRAPH.RO_QUERY" {
+		t.Errorf("Do() = %v, %v, want the reply of the new master", reply, err)
+	}
+}
+
+func Test_sentinelConn_pipeline(t *testing.T) {
+	commands := []string{"GRAPH.QUERY", "GRAPH.RO_QUERY", "GRAPH.QUERY"}
+	tests := []struct {
+		name string
+		// replies read from the old master before it fails. -1 if it fails on flush
+		receivedBefore int
+		// commands sent again on the new master
+		wantResent int
+	}{
+		{"fails-on-flush", -1, 3},
+		{"fails-on-first-receive", 0, 3},
+		{"fails-on-last-receive", 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, resolver, dialed := newFailoverTestConn(t)
+			oldMaster := dialed["10.0.0.1:6379"][0]
+			for _, commandName := range commands {
+				if err := c.Send(commandName); err != nil {
+					t.Fatalf("Send() error = %v", err)
+				}
+			}
+			promote(resolver, "10.0.0.2:6379")
+			if tt.receivedBefore < 0 {
+				oldMaster.err = io.EOF
+			}
+			if err := c.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			for j, commandName := range commands {
+				if j == tt.receivedBefore {
+					oldMaster.err = io.EOF
+				}
+				want := "10.0.0.2:6379 " + commandName
+				if j < tt.receivedBefore {
+					want = "10.0.0.1:6379 " + commandName
+				}
+				if reply, err := c.Receive(); err != nil || reply != want {
+					t.Errorf("Receive() %d = %v, %v, want %v", j, reply, err, want)
+				}
+			}
+			if got := len(dialed["10.0.0.2:6379"][0].sent); got != tt.wantResent {
+				t.Errorf("the new master received %d commands, want %d", got, tt.wantResent)
+			}
+			if len(c.pending) != 0 {
+				t.Errorf("%d commands are still pending", len(c.pending))
+			}
+		})
+	}
+}
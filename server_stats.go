@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverStatsInfoFields are the numeric fields sampled from each INFO section
+var serverStatsInfoFields = map[string][]string{
+	"memory":  {"used_memory", "used_memory_rss", "used_memory_peak", "mem_fragmentation_ratio"},
+	"cpu":     {"used_cpu_sys", "used_cpu_user"},
+	"clients": {"connected_clients", "blocked_clients"},
+}
+
+// statsConnTimeout is the connect, read and write timeout of the stats connections
+const statsConnTimeout = time.Second
+
+// serverStatsCommands are the commands sampled from "INFO commandstats"
+var serverStatsCommands = []string{"graph.query", "graph.ro_query"}
+
+// serverStatsDelta is the change of a server metric along the run
+type serverStatsDelta struct {
+	Metric string  `json:"Metric"`
+	Start  float64 `json:"Start"`
+	End    float64 `json:"End"`
+	Delta  float64 `json:"Delta"`
+	Max    float64 `json:"Max"`
+}
+
+// serverStatsSampler polls the server stats on every reporting period over its own connection,
+// so that the client latency spikes can be correlated with the server memory, cpu and clients.
+// The connection is re-dialed on the next sample whenever it fails ( e.g. after a failover ), and the failing samples are skipped
+type serverStatsSampler struct {
+	dial     func() (redis.Conn, error)
+	conn     redis.Conn
+	graphKey string
+	period   time.Duration
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	// samples keyed by timestamp in milliseconds
+	samples  map[int64]map[string]float64
+	prev     map[string]float64
+	prevTime time.Time
+	// each failing source is only logged once
+	failed map[string]bool
+}
+
+func newServerStatsSampler(dial func() (redis.Conn, error), graphKey string, period time.Duration) *serverStatsSampler {
+	return &serverStatsSampler{
+		dial:     dial,
+		graphKey: graphKey,
+		period:   period,
+		stop:     make(chan struct{}),
+		samples:  map[int64]map[string]float64{},
+		failed:   map[string]bool{},
+	}
+}
+
+// start takes the first sample and keeps sampling on its own go-routine up until finish is called
+func (s *serverStatsSampler) start() {
+	s.sample(time.Now())
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		tick := time.NewTicker(s.period)
+		defer tick.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-tick.C:
+				s.sample(now)
+			}
+		}
+	}()
+}
+
+// finish stops the sampling, takes the last sample and closes the connection
+func (s *serverStatsSampler) finish() {
+	close(s.stop)
+	s.wg.Wait()
+	s.sample(time.Now())
+	s.closeConn()
+}
+
+func (s *serverStatsSampler) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *serverStatsSampler) logFailure(source string, err error) {
+	if !s.failed[source] {
+		s.failed[source] = true
+		log.Printf("Unable to sample the server stats via %s. Continuing anyway. Error: %v\n", source, err)
+	}
+}
+
+func (s *serverStatsSampler) sample(now time.Time) {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			s.logFailure("connection", err)
+			return
+		}
+		s.conn = conn
+	}
+	stats := map[string]float64{}
+	for _, section := range []string{"memory", "cpu", "clients", "commandstats"} {
+		info, err := redis.String(s.conn.Do("INFO", section))
+		if err != nil {
+			s.logFailure("INFO "+section, err)
+			if s.conn.Err() != nil {
+				// the connection is broken, skip the sample and re-dial on the next one
+				s.closeConn()
+				return
+			}
+			continue
+		}
+		var values map[string]float64
+		if section == "commandstats" {
+			values = parseCommandStats(info, serverStatsCommands)
+		} else {
+			values = parseInfoNumericFields(info, serverStatsInfoFields[section])
+		}
+		for metric, value := range values {
+			stats[metric] = value
+		}
+	}
+	usage, err := redis.Int64(s.conn.Do("MEMORY", "USAGE", s.graphKey))
+	if err == redis.ErrNil {
+		// the graph does not exist ( yet )
+		err = nil
+	}
+	if err != nil {
+		s.logFailure("MEMORY USAGE", err)
+		if s.conn.Err() != nil {
+			s.closeConn()
+			return
+		}
+	} else {
+		stats["graph_memory_usage"] = float64(usage)
+	}
+	if cpuPercent, ok := getCpuPercent(s.prev, stats, now.Sub(s.prevTime)); ok {
+		stats["used_cpu_percent"] = cpuPercent
+	}
+	s.prev = stats
+	s.prevTime = now
+	s.samples[now.UnixMilli()] = stats
+}
+
+// getCpuPercent returns the server cpu usage in between two samples, as the percentage of a single core
+func getCpuPercent(prev map[string]float64, current map[string]float64, took time.Duration) (percent float64, ok bool) {
+	if prev == nil || took <= 0 {
+		return
+	}
+	for _, metric := range []string{"used_cpu_sys", "used_cpu_user"} {
+		if _, found := prev[metric]; !found {
+			return
+		}
+		if _, found := current[metric]; !found {
+			return
+		}
+	}
+	used := current["used_cpu_sys"] + current["used_cpu_user"] - prev["used_cpu_sys"] - prev["used_cpu_user"]
+	return used / took.Seconds() * 100.0, true
+}
+
+// parseInfoNumericFields extracts the given numeric fields of an INFO reply. Missing or non numeric fields are skipped
+func parseInfoNumericFields(info string, fields []string) map[string]float64 {
+	values := map[string]float64{}
+	for field, raw := range parseInfoFields(info, fields) {
+		if value, err := strconv.ParseFloat(raw, 64); err == nil {
+			values[field] = value
+		}
+	}
+	return values
+}
+
+// parseCommandStats extracts the stats of the given commands from the "INFO commandstats" reply.
+// Each command is reported in the format cmdstat_<command>:calls=<calls>,usec=<usec>,usec_per_call=<usec_per_call>,...
+// and its stats are returned as <command>_calls, <command>_usec, ...
+func parseCommandStats(info string, commands []string) map[string]float64 {
+	wanted := map[string]bool{}
+	for _, command := range commands {
+		wanted[command] = true
+	}
+	values := map[string]float64{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "cmdstat_") {
+			continue
+		}
+		sepPos := strings.Index(line, ":")
+		if sepPos < 0 {
+			continue
+		}
+		command := strings.ToLower(line[len("cmdstat_"):sepPos])
+		if !wanted[command] {
+			continue
+		}
+		for _, property := range strings.Split(line[sepPos+1:], ",") {
+			kv := strings.SplitN(property, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if value, err := strconv.ParseFloat(kv[1], 64); err == nil {
+				values[fmt.Sprintf("%s_%s", command, kv[0])] = value
+			}
+		}
+	}
+	return values
+}
+
+// getRunTimeStats returns the samples in the ServerRunTimeStats format
+func (s *serverStatsSampler) getRunTimeStats() map[int64]interface{} {
+	runTimeStats := map[int64]interface{}{}
+	for timestamp, stats := range s.samples {
+		runTimeStats[timestamp] = stats
+	}
+	return runTimeStats
+}
+
+// getServerStatsDeltas returns the first, last and max value of each metric along the samples, sorted by metric
+func getServerStatsDeltas(samples map[int64]map[string]float64) []serverStatsDelta {
+	timestamps := make([]int64, 0, len(samples))
+	for timestamp := range samples {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	deltas := map[string]*serverStatsDelta{}
+	for _, timestamp := range timestamps {
+		for metric, value := range samples[timestamp] {
+			delta, found := deltas[metric]
+			if !found {
+				delta = &serverStatsDelta{Metric: metric, Start: value, Max: value}
+				deltas[metric] = delta
+			}
+			delta.End = value
+			delta.Delta = value - delta.Start
+			delta.Max = math.Max(delta.Max, value)
+		}
+	}
+	result := make([]serverStatsDelta, 0, len(deltas))
+	for _, delta := range deltas {
+		result = append(result, *delta)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Metric < result[j].Metric })
+	return result
+}
+
+func formatServerStatsValue(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3f", value)
+}
+
+func renderServerStatsTable(deltas []serverStatsDelta, writer io.Writer, tableTitle string) {
+	data := make([][]string, len(deltas))
+	for i, delta := range deltas {
+		data[i] = []string{delta.Metric, formatServerStatsValue(delta.Start), formatServerStatsValue(delta.End), formatServerStatsValue(delta.Delta), formatServerStatsValue(delta.Max)}
+	}
+	renderSummaryTable(writer, tableTitle, []string{"Metric", "Start", "End", "Delta", "Max"}, data)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_parseCommandStats(t *testing.T) {
+	info := "# Commandstats\r\ncmdstat_info:calls=10,usec=100,usec_per_call=10.00\r\ncmdstat_graph.QUERY:calls=1000,usec=25000,usec_per_call=25.00,rejected_calls=0,failed_calls=2\r\ncmdstat_graph.ro_query:calls=500,usec=5000,usec_per_call=10.00\r\n"
+	want := map[string]float64{
+		"graph.query_calls": 1000, "graph.query_usec": 25000, "graph.query_usec_per_call": 25, "graph.query_rejected_calls": 0, "graph.query_failed_calls": 2,
+		"graph.ro_query_calls": 500, "graph.ro_query_usec": 5000, "graph.ro_query_usec_per_call": 10,
+	}
+	if got := parseCommandStats(info, serverStatsCommands); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCommandStats() = %v, want %v", got, want)
+	}
+}
+
+func Test_parseInfoNumericFields(t *testing.T) {
+	info := "# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\nmem_fragmentation_ratio:1.25\r\n"
+	want := map[string]float64{"used_memory": 1048576, "mem_fragmentation_ratio": 1.25}
+	if got := parseInfoNumericFields(info, []string{"used_memory", "used_memory_human", "mem_fragmentation_ratio", "used_memory_rss"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseInfoNumericFields() = %v, want %v", got, want)
+	}
+}
+
+func Test_getCpuPercent(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    map[string]float64
+		current map[string]float64
+		took    time.Duration
+		want    float64
+		wantOk  bool
+	}{
+		{"first-sample", nil, map[string]float64{"used_cpu_sys": 1, "used_cpu_user": 1}, time.Second, 0, false},
+		{"half-core", map[string]float64{"used_cpu_sys": 1, "used_cpu_user": 1}, map[string]float64{"used_cpu_sys": 1.5, "used_cpu_user": 2}, 3 * time.Second, 50, true},
+		{"missing-cpu-section", map[string]float64{"used_memory": 1}, map[string]float64{"used_cpu_sys": 1.5, "used_cpu_user": 2}, time.Second, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := getCpuPercent(tt.prev, tt.current, tt.took)
+			if ok != tt.wantOk || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("getCpuPercent() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_getServerStatsDeltas(t *testing.T) {
+	samples := map[int64]map[string]float64{
+		3000: {"used_memory": 150, "connected_clients": 1},
+		1000: {"used_memory": 100},
+		2000: {"used_memory": 300, "connected_clients": 51},
+	}
+	want := []serverStatsDelta{
+		{Metric: "connected_clients", Start: 51, End: 1, Delta: -50, Max: 51},
+		{Metric: "used_memory", Start: 100, End: 150, Delta: 50, Max: 300},
+	}
+	if got := getServerStatsDeltas(samples); !reflect.DeepEqual(got, want) {
+		t.Errorf("getServerStatsDeltas() = %v, want %v", got, want)
+	}
+}
+
+func Test_serverStatsSampler_sample(t *testing.T) {
+	var dialed []*fakeConn
+	refuse := true
+	dial := func() (redis.Conn, error) {
+		if refuse {
+			return nil, fmt.Errorf("connection refused")
+		}
+		conn := &fakeConn{addr: "10.0.0.1:6379"}
+		dialed = append(dialed, conn)
+		return conn, nil
+	}
+	s := newServerStatsSampler(dial, "graph", time.Second)
+	now := time.UnixMilli(1000)
+	// the primary is not reachable, the sample is skipped
+	s.sample(now)
+	refuse = false
+	s.sample(now.Add(time.Second))
+	// the connection breaks, the sample is skipped and the connection re-dialed on the next one
+	dialed[0].err = io.EOF
+	s.sample(now.Add(2 * time.Second))
+	s.sample(now.Add(3 * time.Second))
+	if len(dialed) != 2 {
+		t.Errorf("sample() dialed %d connections, want 2", len(dialed))
+	}
+	want := map[int64]bool{2000: true, 4000: true}
+	if len(s.samples) != len(want) {
+		t.Errorf("sample() took %d samples, want %d", len(s.samples), len(want))
+	}
+	for timestamp := range s.samples {
+		if !want[timestamp] {
+			t.Errorf("sample() took an unexpected sample at %d", timestamp)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sloRegexp matches "[<query>:]<metric><operator><threshold>". The query is optional and matched greedily
+// up until the last colon, so that queries containing colons ( e.g. labels ) are supported
+var sloRegexp = regexp.MustCompile(`^(?:(.*):)?\s*(p[0-9]+(?:\.[0-9]+)?|avg|internal-p[0-9]+(?:\.[0-9]+)?|internal-avg|error-rate|throughput)\s*(<=|>=|<|>)\s*([0-9]+(?:\.[0-9]+)?)\s*$`)
+
+// sloCheck is a single service level objective evaluated against the final results of a run.
+// Latencies are in milliseconds, the error rate in percentage and the throughput in requests per second
+type sloCheck struct {
+	spec string
+	// empty for the overall ( all queries ) results
+	query     string
+	metric    string
+	operator  string
+	threshold float64
+}
+
+type SLOResult struct {
+	SLO       string  `json:"SLO"`
+	Query     string  `json:"Query"`
+	Metric    string  `json:"Metric"`
+	Operator  string  `json:"Operator"`
+	Threshold float64 `json:"Threshold"`
+	Value     float64 `json:"Value"`
+	Passed    bool    `json:"Passed"`
+}
+
+func parseSLO(spec string) (slo sloCheck, err error) {
+	matches := sloRegexp.FindStringSubmatch(spec)
+	if matches == nil {
+		err = fmt.Errorf("invalid SLO '%s'. Expected format is [<query>:]<metric><operator><threshold>, for example 'p99<5', 'error-rate<0.1', 'throughput>20000' or 'MATCH (n) RETURN n:p99<5'", spec)
+		return
+	}
+	slo.spec = spec
+	slo.query = strings.TrimSpace(matches[1])
+	slo.metric = matches[2]
+	slo.operator = matches[3]
+	slo.threshold, err = strconv.ParseFloat(matches[4], 64)
+	return
+}
+
+// parseSLOs parses the SLO specs, making sure the queries they refer to are part of the benchmark
+func parseSLOs(specs []string, queries []string) (slos []sloCheck, err error) {
+	slos = make([]sloCheck, 0, len(specs))
+	for _, spec := range specs {
+		var slo sloCheck
+		slo, err = parseSLO(spec)
+		if err != nil {
+			return
+		}
+		if slo.query != "" && slo.query != "Total" && getQueryPos(queries, slo.query) < 0 {
+			err = fmt.Errorf("the SLO '%s' refers to query '%s' which is not part of the benchmark", spec, slo.query)
+			return
+		}
+		slos = append(slos, slo)
+	}
+	return
+}
+
+func getQueryPos(queries []string, query string) int {
+	for i, q := range queries {
+		if q == query {
+			return i
+		}
+	}
+	return -1
+}
+
+// getLatencySLOValue returns the average ( "avg" ) or the quantile ( "pNN" ) of the histogram, in milliseconds
+func getLatencySLOValue(metric string, histogram *hdrhistogram.Histogram) float64 {
+	if metric == "avg" {
+		return histogram.Mean() / 1000.0
+	}
+	quantile, _ := strconv.ParseFloat(strings.TrimPrefix(metric, "p"), 64)
+	return float64(histogram.ValueAtQuantile(quantile)) / 1000.0
+}
+
+// evaluateSLOs checks each SLO against the final histograms and counters of the run
+func evaluateSLOs(slos []sloCheck, queries []string, duration time.Duration) (results []SLOResult) {
+	results = make([]SLOResult, 0, len(slos))
+	for _, slo := range slos {
+		clientHistogram := clientSide_AllQueries_OverallLatencies
+		internalHistogram := serverSide_AllQueries_GraphInternalTime_OverallLatencies
+		errors := totalErrors
+		queryPos := getQueryPos(queries, slo.query)
+		if queryPos >= 0 {
+			clientHistogram = clientSide_PerQuery_OverallLatencies[queryPos]
+			internalHistogram = serverSide_PerQuery_GraphInternalTime_OverallLatencies[queryPos]
+			errors = errorsPerQuery[queryPos]
+		}
+		var value float64
+		switch {
+		case slo.metric == "error-rate":
+			if clientHistogram.TotalCount() > 0 {
+				value = float64(errors) / float64(clientHistogram.TotalCount()) * 100.0
+			}
+		case slo.metric == "throughput":
+			value = float64(clientHistogram.TotalCount()) / duration.Seconds()
+		case strings.HasPrefix(slo.metric, "internal-"):
+			value = getLatencySLOValue(strings.TrimPrefix(slo.metric, "internal-"), internalHistogram)
+		default:
+			value = getLatencySLOValue(slo.metric, clientHistogram)
+		}
+		query := slo.query
+		if queryPos < 0 {
+			query = "Total"
+		}
+		results = append(results, SLOResult{SLO: slo.spec, Query: query, Metric: slo.metric, Operator: slo.operator, Threshold: slo.threshold, Value: value, Passed: checkSLOThreshold(value, slo.operator, slo.threshold)})
+	}
+	return
+}
+
+func checkSLOThreshold(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	}
+	return false
+}
+
+func countFailedSLOs(results []SLOResult) (failed int) {
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return
+}
+
+func renderSLOTable(results []SLOResult, writer io.Writer, tableTitle string) {
+	data := make([][]string, 0, len(results))
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		data = append(data, []string{result.Query, result.Metric, fmt.Sprintf("%s %s", result.Operator, strconv.FormatFloat(result.Threshold, 'f', -1, 64)), fmt.Sprintf("%.3f", result.Value), status})
+	}
+	renderSummaryTable(writer, tableTitle, []string{"Query", "Metric", "Objective", "Value", "Result"}, data)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseSLO(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    sloCheck
+		wantErr bool
+	}{
+		{"overall-p99", "p99<5", sloCheck{spec: "p99<5", metric: "p99", operator: "<", threshold: 5}, false},
+		{"overall-p999", "p99.9 <= 10.5", sloCheck{spec: "p99.9 <= 10.5", metric: "p99.9", operator: "<=", threshold: 10.5}, false},
+		{"error-rate", "error-rate<0.1", sloCheck{spec: "error-rate<0.1", metric: "error-rate", operator: "<", threshold: 0.1}, false},
+		{"throughput", "throughput>20000", sloCheck{spec: "throughput>20000", metric: "throughput", operator: ">", threshold: 20000}, false},
+		{"query-internal", "MATCH (n) RETURN n:internal-avg<1", sloCheck{spec: "MATCH (n) RETURN n:internal-avg<1", query: "MATCH (n) RETURN n", metric: "internal-avg", operator: "<", threshold: 1}, false},
+		{"query-with-colons", "MATCH (n:User) RETURN n:p50<2", sloCheck{spec: "MATCH (n:User) RETURN n:p50<2", query: "MATCH (n:User) RETURN n", metric: "p50", operator: "<", threshold: 2}, false},
+		{"unknown-metric", "p99x<5", sloCheck{}, true},
+		{"missing-threshold", "p99<", sloCheck{}, true},
+		{"missing-operator", "p99 5", sloCheck{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSLO(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSLO() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSLO() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseSLOs(t *testing.T) {
+	queries := []string{"CREATE (n)", "MATCH (n) RETURN n"}
+	if _, err := parseSLOs([]string{"p99<5", "CREATE (n):avg<1", "Total:throughput>100"}, queries); err != nil {
+		t.Errorf("parseSLOs() unexpected error = %v", err)
+	}
+	if _, err := parseSLOs([]string{"MATCH (n) RETURN count(n):p99<5"}, queries); err == nil {
+		t.Errorf("parseSLOs() expected an error for a query that is not part of the benchmark")
+	}
+}
+
+func Test_checkSLOThreshold(t *testing.T) {
+	tests := []struct {
+		value     float64
+		operator  string
+		threshold float64
+		want      bool
+	}{
+		{4, "<", 5, true},
+		{5, "<", 5, false},
+		{5, "<=", 5, true},
+		{6, ">", 5, true},
+		{5, ">", 5, false},
+		{5, ">=", 5, true},
+		{5, "==", 5, false},
+	}
+	for _, tt := range tests {
+		if got := checkSLOThreshold(tt.value, tt.operator, tt.threshold); got != tt.want {
+			t.Errorf("checkSLOThreshold(%v, %s, %v) = %v, want %v", tt.value, tt.operator, tt.threshold, got, tt.want)
+		}
+	}
+}
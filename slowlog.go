@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// slowlogEntry is a GRAPH.SLOWLOG entry
+type slowlogEntry struct {
+	// unix timestamp, in seconds
+	Timestamp      int64   `json:"Timestamp"`
+	Command        string  `json:"Command"`
+	Query          string  `json:"Query"`
+	DurationMillis float64 `json:"DurationMillis"`
+}
+
+// parseSlowlog converts the "GRAPH.SLOWLOG <key>" reply, an array of [timestamp, command, query, duration in ms] entries, into a list.
+// As on the redis helpers, a non nil replyErr is returned as is
+func parseSlowlog(reply interface{}, replyErr error) (entries []slowlogEntry, err error) {
+	var rawEntries []interface{}
+	rawEntries, err = redis.Values(reply, replyErr)
+	if err != nil {
+		return
+	}
+	entries = make([]slowlogEntry, 0, len(rawEntries))
+	for _, rawEntry := range rawEntries {
+		var fields []interface{}
+		fields, err = redis.Values(rawEntry, nil)
+		if err != nil {
+			return
+		}
+		if len(fields) < 4 {
+			err = fmt.Errorf("expected a [timestamp, command, query, duration] entry, got %d elements", len(fields))
+			return
+		}
+		entry := slowlogEntry{}
+		if entry.Timestamp, err = redis.Int64(fields[0], nil); err != nil {
+			return
+		}
+		if entry.Command, err = redis.String(fields[1], nil); err != nil {
+			return
+		}
+		if entry.Query, err = redis.String(fields[2], nil); err != nil {
+			return
+		}
+		if entry.DurationMillis, err = redis.Float64(fields[3], nil); err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// mergeSlowlogEntries merges the fetched entries into the current ones and returns the slowest maxEntries, slowest first.
+// Entries are deduplicated by command and query, keeping the slowest execution ( and its timestamp ),
+// given the same query is reported on every fetch and by each of the endpoints
+func mergeSlowlogEntries(current []slowlogEntry, fetched []slowlogEntry, maxEntries int) []slowlogEntry {
+	type slowlogKey struct {
+		command string
+		query   string
+	}
+	slowestPos := map[slowlogKey]int{}
+	merged := make([]slowlogEntry, 0, len(current)+len(fetched))
+	for _, entries := range [][]slowlogEntry{current, fetched} {
+		for _, entry := range entries {
+			key := slowlogKey{entry.Command, entry.Query}
+			pos, found := slowestPos[key]
+			if !found {
+				slowestPos[key] = len(merged)
+				merged = append(merged, entry)
+			} else if entry.DurationMillis > merged[pos].DurationMillis {
+				merged[pos] = entry
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].DurationMillis > merged[j].DurationMillis })
+	if len(merged) > maxEntries {
+		merged = merged[:maxEntries]
+	}
+	return merged
+}
+
+// slowlogCollector resets the graph slowlog of each endpoint ( primary and replicas ) when started and collects it when finished.
+// Given the slowlog only keeps a few entries, it can also be fetched periodically during the run.
+// Each endpoint connection is re-dialed on the next fetch whenever it fails ( e.g. after a failover )
+type slowlogCollector struct {
+	endpoints  []string
+	dial       func(endpointPos int) (redis.Conn, error)
+	conns      []redis.Conn
+	graphKey   string
+	period     time.Duration
+	maxEntries int
+	stop       chan struct{}
+	wg         sync.WaitGroup
+	entries    []slowlogEntry
+	// fetch errors are only logged once per endpoint
+	failed map[string]bool
+}
+
+func newSlowlogCollector(endpoints []string, dial func(endpointPos int) (redis.Conn, error), graphKey string, period time.Duration, maxEntries int) *slowlogCollector {
+	return &slowlogCollector{
+		endpoints:  endpoints,
+		dial:       dial,
+		conns:      make([]redis.Conn, len(endpoints)),
+		graphKey:   graphKey,
+		period:     period,
+		maxEntries: maxEntries,
+		stop:       make(chan struct{}),
+		entries:    []slowlogEntry{},
+		failed:     map[string]bool{},
+	}
+}
+
+// start resets the slowlog of each endpoint, so that only the queries of the run are collected, and starts the periodic fetch ( if enabled )
+func (c *slowlogCollector) start() {
+	for pos, endpoint := range c.endpoints {
+		if _, err := c.do(pos, "GRAPH.SLOWLOG", c.graphKey, "RESET"); err != nil {
+			log.Printf("Unable to reset GRAPH.SLOWLOG on %s. The slowlog might include queries from before the run. Error: %v\n", endpoint, err)
+		}
+	}
+	if c.period <= 0 {
+		return
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		tick := time.NewTicker(c.period)
+		defer tick.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-tick.C:
+				c.fetch()
+			}
+		}
+	}()
+}
+
+// do issues the command on the given endpoint, dialing it if required. Broken connections are closed so that the next call re-dials
+func (c *slowlogCollector) do(endpointPos int, commandName string, args ...interface{}) (reply interface{}, err error) {
+	if c.conns[endpointPos] == nil {
+		if c.conns[endpointPos], err = c.dial(endpointPos); err != nil {
+			c.conns[endpointPos] = nil
+			return
+		}
+	}
+	conn := c.conns[endpointPos]
+	reply, err = conn.Do(commandName, args...)
+	if conn.Err() != nil {
+		conn.Close()
+		c.conns[endpointPos] = nil
+	}
+	return
+}
+
+func (c *slowlogCollector) fetch() {
+	for pos, endpoint := range c.endpoints {
+		fetched, err := parseSlowlog(c.do(pos, "GRAPH.SLOWLOG", c.graphKey))
+		if err != nil {
+			if !c.failed[endpoint] {
+				c.failed[endpoint] = true
+				log.Printf("Unable to fetch GRAPH.SLOWLOG from %s. Continuing anyway. Error: %v\n", endpoint, err)
+			}
+			continue
+		}
+		c.entries = mergeSlowlogEntries(c.entries, fetched, c.maxEntries)
+	}
+}
+
+// finish stops the periodic fetch, does a last fetch and closes the connections. It returns the slowest entries, slowest first
+func (c *slowlogCollector) finish() []slowlogEntry {
+	close(c.stop)
+	c.wg.Wait()
+	c.fetch()
+	for _, conn := range c.conns {
+		if conn != nil {
+			conn.Close()
+		}
+	}
+	return c.entries
+}
+
+func renderSlowlogTable(entries []slowlogEntry, writer io.Writer, tableTitle string) {
+	data := make([][]string, len(entries))
+	for i, entry := range entries {
+		data[i] = []string{time.Unix(entry.Timestamp, 0).UTC().Format(time.RFC3339), entry.Command, entry.Query, fmt.Sprintf("%.3f", entry.DurationMillis)}
+	}
+	renderSummaryTable(writer, tableTitle, []string{"Timestamp", "Command", "Query", "Duration(ms)"}, data)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func Test_parseSlowlog(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    interface{}
+		replyErr error
+		want     []slowlogEntry
+		wantErr  bool
+	}{
+		{"entries", []interface{}{
+			[]interface{}{[]byte("1792400000"), []byte("GRAPH.QUERY"), []byte("MATCH (u:User {id: 42}) RETURN u"), []byte("12.5")},
+			[]interface{}{[]byte("1792400001"), []byte("GRAPH.RO_QUERY"), []byte("MATCH (n) RETURN count(n)"), []byte("3")},
+		}, nil, []slowlogEntry{
+			{1792400000, "GRAPH.QUERY", "MATCH (u:User {id: 42}) RETURN u", 12.5},
+			{1792400001, "GRAPH.RO_QUERY", "MATCH (n) RETURN count(n)", 3},
+		}, false},
+		{"empty", []interface{}{}, nil, []slowlogEntry{}, false},
+		{"missing-fields", []interface{}{[]interface{}{[]byte("1792400000"), []byte("GRAPH.QUERY")}}, nil, nil, true},
+		{"invalid-duration", []interface{}{[]interface{}{[]byte("1792400000"), []byte("GRAPH.QUERY"), []byte("CREATE (n)"), []byte("slow")}}, nil, nil, true},
+		{"connection-error", nil, fmt.Errorf("connection refused"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSlowlog(tt.reply, tt.replyErr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSlowlog() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSlowlog() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mergeSlowlogEntries(t *testing.T) {
+	current := []slowlogEntry{{100, "GRAPH.QUERY", "CREATE (n)", 20}, {101, "GRAPH.QUERY", "CREATE (n)", 5}}
+	// the second fetch still includes the first entry, and the same queries are only reported once, as their slowest execution
+	fetched := []slowlogEntry{{100, "GRAPH.QUERY", "CREATE (n)", 20}, {102, "GRAPH.RO_QUERY", "MATCH (n) RETURN n", 30}, {103, "GRAPH.QUERY", "CREATE (n)", 1}, {104, "GRAPH.RO_QUERY", "MATCH (n) RETURN n", 40}}
+	want := []slowlogEntry{{104, "GRAPH.RO_QUERY", "MATCH (n) RETURN n", 40}, {100, "GRAPH.QUERY", "CREATE (n)", 20}}
+	if got := mergeSlowlogEntries(current, fetched, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSlowlogEntries() = %v, want %v", got, want)
+	}
+}
+
+// fakeSlowlogConn replies to GRAPH.SLOWLOG with the given entries
+type fakeSlowlogConn struct {
+	fakeConn
+	entries []interface{}
+}
+
+func (c *fakeSlowlogConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if _, err := c.fakeConn.Do(commandName, args...); err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		// RESET
+		return "OK", nil
+	}
+	return c.entries, nil
+}
+
+func Test_slowlogCollector(t *testing.T) {
+	entries := map[int][]interface{}{
+		0: {[]interface{}{[]byte("100"), []byte("GRAPH.QUERY"), []byte("CREATE (n)"), []byte("20")}},
+		1: {[]interface{}{[]byte("101"), []byte("GRAPH.RO_QUERY"), []byte("MATCH (n) RETURN n"), []byte("30")}},
+	}
+	dialed := map[int][]*fakeSlowlogConn{}
+	dial := func(endpointPos int) (redis.Conn, error) {
+		conn := &fakeSlowlogConn{entries: entries[endpointPos]}
+		dialed[endpointPos] = append(dialed[endpointPos], conn)
+		return conn, nil
+	}
+	c := newSlowlogCollector([]string{"10.0.0.1:6379", "10.0.0.2:6379"}, dial, "graph", 0, 10)
+	c.start()
+	// the replica connection breaks along the run, and is re-dialed on the final fetch
+	dialed[1][0].err = io.EOF
+	c.fetch()
+	entries[1] = append(entries[1], []interface{}{[]byte("102"), []byte("GRAPH.RO_QUERY"), []byte("MATCH (n) RETURN count(n)"), []byte("10")})
+	got := c.finish()
+	want := []slowlogEntry{{101, "GRAPH.RO_QUERY", "MATCH (n) RETURN n", 30}, {100, "GRAPH.QUERY", "CREATE (n)", 20}, {102, "GRAPH.RO_QUERY", "MATCH (n) RETURN count(n)", 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("finish() = %v, want %v", got, want)
+	}
+	if len(dialed[0]) != 1 || len(dialed[1]) != 2 {
+		t.Errorf("dialed %d primary and %d replica connections, want 1 and 2", len(dialed[0]), len(dialed[1]))
+	}
+}
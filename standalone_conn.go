@@ -10,13 +10,22 @@ import (
 )
 
 func getStandaloneConn(graphName, network, addr string, password string, tlsCaCertFile string) (graph rg.Graph, conn redis.Conn) {
+	conn, err := dialStandalone(network, addr, password, tlsCaCertFile)
+	if err != nil {
+		log.Fatalf("Error preparing for benchmark, while creating new connection. error = %v", err)
+	}
+	return rg.GraphNew(graphName, conn), conn
+}
 
-	var err error
+// dialStandalone opens a new connection to the given address, using TLS if a CA cert file is specified.
+// The extra options ( e.g. timeouts ) are passed as is to redis.Dial
+func dialStandalone(network, addr string, password string, tlsCaCertFile string, options ...redis.DialOption) (conn redis.Conn, err error) {
+	dialOptions := append([]redis.DialOption{}, options...)
 	if tlsCaCertFile != "" {
 		// Load CA cert
-		caCert, err := ioutil.ReadFile(tlsCaCertFile)
-		if err != nil {
-			log.Fatal(err)
+		caCert, readErr := ioutil.ReadFile(tlsCaCertFile)
+		if readErr != nil {
+			log.Fatal(readErr)
 		}
 		caCertPool := x509.NewCertPool()
 		caCertPool.AppendCertsFromPEM(caCert)
@@ -31,29 +40,15 @@ func getStandaloneConn(graphName, network, addr string, password string, tlsCaCe
 		// In this mode, TLS is susceptible to man-in-the-middle attacks.
 		// This should be used only for testing.
 		clientTLSConfig.InsecureSkipVerify = true
-		if password != "" {
-			conn, err = redis.Dial(network, addr,
-				redis.DialPassword(password),
-				redis.DialTLSConfig(clientTLSConfig),
-				redis.DialUseTLS(true),
-				redis.DialTLSSkipVerify(true),
-			)
-		} else {
-			conn, err = redis.Dial(network, addr,
-				redis.DialTLSConfig(clientTLSConfig),
-				redis.DialUseTLS(true),
-				redis.DialTLSSkipVerify(true),
-			)
-		}
-	} else {
-		if password != "" {
-			conn, err = redis.Dial(network, addr, redis.DialPassword(password))
-		} else {
-			conn, err = redis.Dial(network, addr)
-		}
+		dialOptions = append(dialOptions,
+			redis.DialTLSConfig(clientTLSConfig),
+			redis.DialUseTLS(true),
+			redis.DialTLSSkipVerify(true),
+		)
 	}
-	if err != nil {
-		log.Fatalf("Error preparing for benchmark, while creating new connection. error = %v", err)
+	if password != "" {
+		dialOptions = append(dialOptions, redis.DialPassword(password))
 	}
-	return rg.GraphNew(graphName, conn), conn
+	conn, err = redis.Dial(network, addr, dialOptions...)
+	return
 }
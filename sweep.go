@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// uint64ListParameter is a flag accepting either a single value or a comma separated list of values
+type uint64ListParameter []uint64
+
+func (l *uint64ListParameter) String() string {
+	values := make([]string, len(*l))
+	for i, v := range *l {
+		values[i] = strconv.FormatUint(v, 10)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *uint64ListParameter) Set(value string) error {
+	parsed := uint64ListParameter{}
+	for _, s := range strings.Split(value, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, v)
+	}
+	*l = parsed
+	return nil
+}
+
+// int64ListParameter is a flag accepting either a single value or a comma separated list of values
+type int64ListParameter []int64
+
+func (l *int64ListParameter) String() string {
+	values := make([]string, len(*l))
+	for i, v := range *l {
+		values[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(values, ",")
+}
+
+func (l *int64ListParameter) Set(value string) error {
+	parsed := int64ListParameter{}
+	for _, s := range strings.Split(value, ",") {
+		v, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, v)
+	}
+	*l = parsed
+	return nil
+}
+
+type SweepPointResult struct {
+	Clients                uint64             `json:"Clients"`
+	TargetRps              int64              `json:"TargetRps"`
+	Pipeline               uint64             `json:"Pipeline"`
+	AchievedRps            float64            `json:"AchievedRps"`
+	IssuedCommands         uint64             `json:"IssuedCommands"`
+	ErrorRate              float64            `json:"ErrorRate"`
+	ClientLatencies        map[string]float64 `json:"ClientLatencies"`
+	GraphInternalLatencies map[string]float64 `json:"GraphInternalLatencies"`
+	// whether the graph was deleted after this combination ran
+	GraphDeleted bool        `json:"GraphDeleted"`
+	Result       *TestResult `json:"Result"`
+}
+
+// getSweepWorkloads returns one workload per clients, rps and pipeline combination,
+// ordered by clients, then rps, then pipeline
+func getSweepWorkloads(workload benchmarkWorkload, clients []uint64, rps []int64, pipeline []uint64) (workloads []benchmarkWorkload) {
+	workloads = make([]benchmarkWorkload, 0, len(clients)*len(rps)*len(pipeline))
+	for _, c := range clients {
+		for _, r := range rps {
+			for _, p := range pipeline {
+				w := workload
+				w.clients = c
+				w.rps = r
+				w.pipeline = p
+				w.name = fmt.Sprintf("clients %d rps %d pipeline %d", c, r, p)
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	return
+}
+
+// runSweep runs each of the workloads sequentially, optionally deleting the graph in between them
+func runSweep(runner *benchmarkRunner, workloads []benchmarkWorkload, resetGraph bool) (sweepResults []SweepPointResult, completed bool) {
+	sweepResults = make([]SweepPointResult, 0, len(workloads))
+	completed = true
+	for i, w := range workloads {
+		log.Printf("Running sweep combination %d/%d: %s\n", i+1, len(workloads), w.name)
+		var result *TestResult
+		result, completed = runner.run(w)
+		_, clientLatencies := generateLatenciesMap(clientSide_AllQueries_OverallLatencies)
+		_, graphInternalLatencies := generateLatenciesMap(serverSide_AllQueries_GraphInternalTime_OverallLatencies)
+		errorRate := 0.0
+		if result.IssuedCommands > 0 {
+			errorRate = float64(totalErrors) / float64(result.IssuedCommands) * 100.0
+		}
+		point := SweepPointResult{
+			Clients:                w.clients,
+			TargetRps:              w.rps,
+			Pipeline:               w.pipeline,
+			AchievedRps:            float64(result.IssuedCommands) / (float64(result.DurationMillis) / 1000.0),
+			IssuedCommands:         result.IssuedCommands,
+			ErrorRate:              errorRate,
+			ClientLatencies:        clientLatencies,
+			GraphInternalLatencies: graphInternalLatencies,
+			Result:                 result,
+		}
+		if completed && resetGraph && i < len(workloads)-1 {
+			log.Printf("Deleting graph %s\n", runner.graphKey)
+			if err := runner.deleteGraph(); err != nil {
+				log.Printf("Unable to delete graph %s. Error: %v\n", runner.graphKey, err)
+			} else {
+				point.GraphDeleted = true
+			}
+		}
+		sweepResults = append(sweepResults, point)
+		if !completed {
+			log.Printf("Sweep combination %s was interrupted. Skipping the remaining combinations\n", w.name)
+			break
+		}
+	}
+	printSweepSummary(sweepResults)
+	return
+}
+
+func getSweepTableHeader() []string {
+	header := []string{"Clients", "Target rps", "Pipeline", "Issued commands", "Achieved rps", "Error rate(%)"}
+	for _, percentile := range getTablePercentiles() {
+		header = append(header, fmt.Sprintf("%s latency(ms)", getPercentileLabel(percentile)))
+	}
+	return header
+}
+
+func getSweepTableLine(point SweepPointResult) []string {
+	line := []string{
+		fmt.Sprintf("%d", point.Clients),
+		fmt.Sprintf("%d", point.TargetRps),
+		fmt.Sprintf("%d", point.Pipeline),
+		fmt.Sprintf("%d", point.IssuedCommands),
+		fmt.Sprintf("%.0f", point.AchievedRps),
+		fmt.Sprintf("%.3f", point.ErrorRate),
+	}
+	for _, percentile := range getTablePercentiles() {
+		line = append(line, fmt.Sprintf("%.3f", point.ClientLatencies[getPercentileKey(percentile)]))
+	}
+	return line
+}
+
+func printSweepSummary(sweepResults []SweepPointResult) {
+	writer := os.Stdout
+	fmt.Fprintf(writer, "## Sweep summary table\n")
+	table := tablewriter.NewWriter(writer)
+	table.SetHeader(getSweepTableHeader())
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, point := range sweepResults {
+		table.Append(getSweepTableLine(point))
+	}
+	table.Render()
+}
+
+func saveSweepCsv(sweepResults []SweepPointResult, fileName string) (err error) {
+	log.Printf("Saving sweep CSV results file to %s\n", fileName)
+	var f *os.File
+	f, err = os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err = w.Write(getSweepTableHeader()); err != nil {
+		return
+	}
+	for _, point := range sweepResults {
+		if err = w.Write(getSweepTableLine(point)); err != nil {
+			return
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
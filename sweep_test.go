@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_uint64ListParameter_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    uint64ListParameter
+		wantErr bool
+	}{
+		{"single", "50", uint64ListParameter{50}, false},
+		{"list", "10,50,100", uint64ListParameter{10, 50, 100}, false},
+		{"list-with-spaces", "10, 50", uint64ListParameter{10, 50}, false},
+		{"negative", "-1", nil, true},
+		{"invalid", "10,a", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := uint64ListParameter{1}
+			err := l.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(l, tt.want) {
+				t.Errorf("Set() = %v, want %v", l, tt.want)
+			}
+		})
+	}
+}
+
+func Test_int64ListParameter_Set(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int64ListParameter
+		wantErr bool
+	}{
+		{"single", "0", int64ListParameter{0}, false},
+		{"list", "0,1000,5000", int64ListParameter{0, 1000, 5000}, false},
+		{"invalid", "1000,", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := int64ListParameter{0}
+			err := l.Set(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Set() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(l, tt.want) {
+				t.Errorf("Set() = %v, want %v", l, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getSweepWorkloads(t *testing.T) {
+	workload := benchmarkWorkload{clients: 50, rps: 0, pipeline: 1, numberRequests: 1000}
+	got := getSweepWorkloads(workload, []uint64{1, 10}, []int64{0, 1000}, []uint64{1})
+	want := [][3]int64{{1, 0, 1}, {1, 1000, 1}, {10, 0, 1}, {10, 1000, 1}}
+	if len(got) != len(want) {
+		t.Fatalf("getSweepWorkloads() returned %d workloads, want %d", len(got), len(want))
+	}
+	for i, w := range got {
+		if int64(w.clients) != want[i][0] || w.rps != want[i][1] || int64(w.pipeline) != want[i][2] || w.numberRequests != 1000 {
+			t.Errorf("getSweepWorkloads()[%d] = clients %d rps %d pipeline %d, want %v", i, w.clients, w.rps, w.pipeline, want[i])
+		}
+	}
+}
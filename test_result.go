@@ -13,10 +13,12 @@ import (
 	"time"
 )
 
+// resultFormatVersion is bumped whenever the json results change in a non backwards compatible way
 const resultFormatVersion = "0.0.1"
 
 type GraphQueryDatapoint struct {
 	CmdPos                      int // command that was used
+	EndpointPos                 int // endpoint that served the command
 	ClientDurationMicros        int64
 	GraphInternalDurationMicros int64
 	Error                       bool
@@ -32,14 +34,16 @@ type GraphQueryDatapoint struct {
 type TestResult struct {
 
 	// Test Configs
-	resultFormatVersion              string `json:"ResultFormatVersion"`
-	Metadata                         string `json:"Metadata"`
-	Clients                          uint   `json:"Clients"`
-	MaxRps                           uint64 `json:"MaxRps"`
-	RandomSeed                       int64  `json:"RandomSeed"`
-	BenchmarkConfiguredCommandsLimit uint64 `json:"BenchmarkConfiguredCommandsLimit"`
-	IssuedCommands                   uint64 `json:"IssuedCommands"`
-	BenchmarkFullyRun                bool   `json:"BenchmarkFullyRun"`
+	ResultFormatVersion               string `json:"ResultFormatVersion"`
+	Metadata                          string `json:"Metadata"`
+	Clients                           uint   `json:"Clients"`
+	MaxRps                            uint64 `json:"MaxRps"`
+	Pipeline                          uint64 `json:"Pipeline"`
+	RandomSeed                        int64  `json:"RandomSeed"`
+	BenchmarkConfiguredCommandsLimit  uint64 `json:"BenchmarkConfiguredCommandsLimit"`
+	BenchmarkConfiguredDurationMillis int64  `json:"BenchmarkConfiguredDurationMillis"`
+	IssuedCommands                    uint64 `json:"IssuedCommands"`
+	BenchmarkFullyRun                 bool   `json:"BenchmarkFullyRun"`
 
 	// Test Description
 	TestDescription string `json:"TestDescription"`
@@ -51,6 +55,12 @@ type TestResult struct {
 	EndTime        int64 `json:"EndTime"`
 	DurationMillis int64 `json:"DurationMillis"`
 
+	// Start of the measured window ( after the warmup, if any )
+	MeasurementStartTime int64 `json:"MeasurementStartTime"`
+
+	// Warmup stats. Not accounted on any of the overall results
+	Warmup map[string]interface{} `json:"Warmup"`
+
 	// Populated after benchmark
 	// Benchmark Totals
 	Totals map[string]interface{} `json:"Totals"`
@@ -64,21 +74,66 @@ type TestResult struct {
 	// Overall Graph Internal Quantiles
 	OverallGraphInternalLatencies map[string]interface{} `json:"OverallGraphInternalLatencies"`
 
+	// Overall Client latency histograms, per query, as base64 compressed HdrHistogram snapshots ( microseconds )
+	EncodedClientHistograms map[string]string `json:"EncodedClientHistograms"`
+
+	// Overall Graph Internal latency histograms, per query, as base64 compressed HdrHistogram snapshots ( microseconds )
+	EncodedGraphInternalHistograms map[string]string `json:"EncodedGraphInternalHistograms"`
+
 	// Relative Internal External Latencies Differences
 	RelativeInternalExternalLatencyDiff map[string]float64 `json:"OverallRelativeInternalExternalLatencyDiff"`
 
 	// Relative Internal External Latencies Differences
 	AbsoluteInternalExternalLatencyDiff map[string]float64 `json:"OverallAbsoluteInternalExternalLatencyDiff"`
 
+	// Endpoints used on the benchmark. The first one is the primary, followed by the read-only replicas
+	Endpoints []string `json:"Endpoints"`
+
+	// Overall Rates per endpoint
+	OverallEndpointRates map[string]interface{} `json:"OverallEndpointRates"`
+
+	// Overall Client Quantiles per endpoint
+	OverallEndpointClientLatencies map[string]interface{} `json:"OverallEndpointClientLatencies"`
+
+	// Overall Graph Internal Quantiles per endpoint
+	OverallEndpointGraphInternalLatencies map[string]interface{} `json:"OverallEndpointGraphInternalLatencies"`
+
+	// Service level objectives evaluated against the final results
+	SLOs []SLOResult `json:"SLOs"`
+
+	// Master changes detected via sentinel during the benchmark
+	FailoverEvents []FailoverEvent `json:"FailoverEvents"`
+
+	// Per phase results, when running a multi-phase scenario
+	Phases []PhaseResult `json:"Phases"`
+
+	// Per step results, when running a stepped load ramp
+	Ramp *RampResult `json:"Ramp"`
+
+	// Per combination results, when running a clients/rps/pipeline sweep
+	Sweep []SweepPointResult `json:"Sweep"`
+
+	// Aggregated results, when running the same benchmark several times
+	Repetitions *RepetitionsResult `json:"Repetitions"`
+
 	// Per second ( tick ) client stats
 	ClientRunTimeStats map[int64]interface{} `json:"ClientRunTimeStats"`
 
 	// Per second ( tick ) server stats
 	ServerRunTimeStats map[int64]interface{} `json:"ServerRunTimeStats"`
+
+	// Change of each sampled server stat along the run
+	ServerStats []serverStatsDelta `json:"ServerStats"`
+
+	// Slowest queries of the run, as reported by GRAPH.SLOWLOG, slowest first
+	Slowlog []slowlogEntry `json:"Slowlog"`
+
+	// Samples exported, dropped and failed by the RedisTimeSeries exporter, when enabled
+	RedisTimeSeriesExporter *rtsExporterStats `json:"RedisTimeSeriesExporter"`
 }
 
 func NewTestResult(metadata string, clients uint, commandsLimit uint64, maxRps uint64, testDescription string) *TestResult {
-	return &TestResult{resultFormatVersion: resultFormatVersion, BenchmarkConfiguredCommandsLimit: commandsLimit, BenchmarkFullyRun: false, Metadata: metadata, Clients: clients, MaxRps: maxRps, TestDescription: testDescription}
+	return &TestResult{ResultFormatVersion: resultFormatVersion, BenchmarkConfiguredCommandsLimit: commandsLimit, BenchmarkFullyRun: false, Metadata: metadata, Clients: clients, MaxRps: maxRps, TestDescription: testDescription}
 }
 
 func (r *TestResult) SetUsedRandomSeed(seed int64) *TestResult {
@@ -86,34 +141,89 @@ func (r *TestResult) SetUsedRandomSeed(seed int64) *TestResult {
 	return r
 }
 
+// FailedSLOs returns the number of failed SLOs, including the ones of each phase, sweep combination and repetition
+func (r *TestResult) FailedSLOs() (failed int) {
+	failed = countFailedSLOs(r.SLOs)
+	for _, phase := range r.Phases {
+		if phase.Result != nil {
+			failed += phase.Result.FailedSLOs()
+		}
+	}
+	for _, point := range r.Sweep {
+		failed += point.Result.FailedSLOs()
+	}
+	if r.Repetitions != nil {
+		for _, run := range r.Repetitions.Runs {
+			failed += run.FailedSLOs()
+		}
+	}
+	if r.Ramp != nil {
+		for _, step := range r.Ramp.Steps {
+			failed += countFailedSLOs(step.SLOs)
+		}
+	}
+	return
+}
+
 func (r *TestResult) FillDurationInfo(startTime time.Time, endTime time.Time, duration time.Duration) {
 	r.StartTime = startTime.UTC().UnixNano() / 1000000
 	r.EndTime = endTime.UTC().UnixNano() / 1000000
 	r.DurationMillis = duration.Milliseconds()
+	r.MeasurementStartTime = r.StartTime
+}
+
+func (r *TestResult) FillWarmupInfo(warmupStartTime time.Time, warmupEndTime time.Time, queries []string) {
+	clientLatencies, _ := GetOverallLatencies(queries, clientSide_PerQuery_WarmupLatencies, clientSide_AllQueries_WarmupLatencies)
+	graphInternalLatencies, _ := GetOverallLatencies(queries, serverSide_PerQuery_GraphInternalTime_WarmupLatencies, serverSide_AllQueries_GraphInternalTime_WarmupLatencies)
+	r.Warmup = map[string]interface{}{
+		"StartTime":              warmupStartTime.UTC().UnixNano() / 1000000,
+		"EndTime":                warmupEndTime.UTC().UnixNano() / 1000000,
+		"DurationMillis":         warmupEndTime.Sub(warmupStartTime).Milliseconds(),
+		"IssuedCommands":         warmupCommands,
+		"Errors":                 warmupErrors,
+		"ErrorsPerQuery":         warmupErrorsPerQuery,
+		"ClientLatencies":        clientLatencies,
+		"GraphInternalLatencies": graphInternalLatencies,
+	}
 }
 
-func processGraphDatapointsChannel(graphStatsChann chan GraphQueryDatapoint, c chan os.Signal, numberRequests uint64, wg *sync.WaitGroup, instantMutex *sync.Mutex) {
+// processGraphDatapointsChannel processes the datapoints up until the stats channel is closed ( after all clients finished )
+func processGraphDatapointsChannel(graphStatsChann chan GraphQueryDatapoint, warmupStatsChann chan GraphQueryDatapoint, c chan os.Signal, wg *sync.WaitGroup, instantMutex *sync.Mutex) {
 	defer wg.Done()
-	var totalProcessedCommands uint64 = 0
 	for {
 		select {
-		case dp := <-graphStatsChann:
+		case dp, ok := <-graphStatsChann:
 			{
+				if !ok {
+					// make sure no warmup datapoint is left behind
+					for {
+						select {
+						case dp := <-warmupStatsChann:
+							processWarmupDatapoint(dp)
+						default:
+							return
+						}
+					}
+				}
 				cmdPos := dp.CmdPos
+				endpointPos := dp.EndpointPos
 				clientDurationMicros := dp.ClientDurationMicros
+				// the counters and histograms are read concurrently by the metrics endpoint and the exporters, holding the same mutex
 				instantMutex.Lock()
 				clientSide_PerQuery_OverallLatencies[cmdPos].RecordValue(clientDurationMicros)
+				clientSide_PerEndpoint_OverallLatencies[endpointPos].RecordValue(clientDurationMicros)
 				clientSide_AllQueries_OverallLatencies.RecordValue(clientDurationMicros)
 				graphInternalDurationMicros := dp.GraphInternalDurationMicros
 				serverSide_PerQuery_GraphInternalTime_OverallLatencies[cmdPos].RecordValue(graphInternalDurationMicros)
+				serverSide_PerEndpoint_GraphInternalTime_OverallLatencies[endpointPos].RecordValue(graphInternalDurationMicros)
 				serverSide_AllQueries_GraphInternalTime_OverallLatencies.RecordValue(graphInternalDurationMicros)
-				instantMutex.Unlock()
 				// Only needs to be atomic due to CLI print
 				atomic.AddUint64(&totalCommands, uint64(1))
 				if dp.Error {
 					// Only needs to be atomic due to CLI print
 					atomic.AddUint64(&totalErrors, uint64(1))
 					errorsPerQuery[cmdPos]++
+					errorsPerEndpoint[endpointPos]++
 				} else {
 					totalNodesCreated = totalNodesCreated + dp.NodesCreated
 					totalNodesDeleted = totalNodesDeleted + dp.NodesDeleted
@@ -134,27 +244,37 @@ func processGraphDatapointsChannel(graphStatsChann chan GraphQueryDatapoint, c c
 					}
 				}
 
-				instantMutex.Lock()
 				clientSide_AllQueries_InstantLatencies.RecordValue(clientDurationMicros)
 				serverSide_AllQueries_GraphInternalTime_InstantLatencies.RecordValue(graphInternalDurationMicros)
+				clientSide_PerQuery_InstantLatencies[cmdPos].RecordValue(clientDurationMicros)
+				serverSide_PerQuery_GraphInternalTime_InstantLatencies[cmdPos].RecordValue(graphInternalDurationMicros)
 				instantMutex.Unlock()
-
-				totalProcessedCommands++
-				// if all commands have been processed return
-				// otherwise keep looping
-				if totalProcessedCommands >= numberRequests {
-					return
-				}
 				break
 			}
 
+		case dp := <-warmupStatsChann:
+			processWarmupDatapoint(dp)
+
 		case <-c:
-			fmt.Println("\nReceived Ctrl-c - shutting down datapoints processor go-routine")
+			fmt.Fprintln(getProgressWriter(), "\nReceived Ctrl-c - shutting down datapoints processor go-routine")
 			return
 		}
 	}
 }
 
+func processWarmupDatapoint(dp GraphQueryDatapoint) {
+	clientSide_PerQuery_WarmupLatencies[dp.CmdPos].RecordValue(dp.ClientDurationMicros)
+	clientSide_AllQueries_WarmupLatencies.RecordValue(dp.ClientDurationMicros)
+	serverSide_PerQuery_GraphInternalTime_WarmupLatencies[dp.CmdPos].RecordValue(dp.GraphInternalDurationMicros)
+	serverSide_AllQueries_GraphInternalTime_WarmupLatencies.RecordValue(dp.GraphInternalDurationMicros)
+	// Only needs to be atomic due to CLI print
+	atomic.AddUint64(&warmupCommands, uint64(1))
+	if dp.Error {
+		atomic.AddUint64(&warmupErrors, uint64(1))
+		warmupErrorsPerQuery[dp.CmdPos]++
+	}
+}
+
 func saveJsonResult(testResult *TestResult, jsonOutputFile *string) {
 	file, err := json.MarshalIndent(testResult, "", " ")
 	if err != nil {
@@ -174,24 +294,20 @@ func calculateRateMetrics(current, prev int64, took time.Duration) (rate float64
 
 func generateLatenciesMap(hist *hdrhistogram.Histogram) (int64, map[string]float64) {
 	ops := hist.TotalCount()
-	q0 := 0.0
-	q50 := 0.0
-	q95 := 0.0
-	q99 := 0.0
-	q999 := 0.0
-	q100 := 0.0
-	average := 0.0
+	values := map[string]float64{}
 	if ops > 0 {
-		q0 = float64(hist.ValueAtQuantile(0.0)) / 10e2
-		q50 = float64(hist.ValueAtQuantile(50.0)) / 10e2
-		q95 = float64(hist.ValueAtQuantile(95.0)) / 10e2
-		q99 = float64(hist.ValueAtQuantile(99.0)) / 10e2
-		q999 = float64(hist.ValueAtQuantile(99.90)) / 10e2
-		q100 = float64(hist.ValueAtQuantile(100.0)) / 10e2
-		average = (hist.Mean() / float64(1000.0))
+		for _, percentile := range reportedPercentiles {
+			values[getPercentileKey(percentile)] = float64(hist.ValueAtQuantile(percentile)) / 10e2
+		}
+		values["min"] = float64(hist.Min()) / 10e2
+		values["max"] = float64(hist.Max()) / 10e2
+		values["avg"] = hist.Mean() / float64(1000.0)
+		values["stddev"] = hist.StdDev() / float64(1000.0)
+	}
+	mp := map[string]float64{}
+	for _, key := range getLatencyMapKeys() {
+		mp[key] = values[key]
 	}
-
-	mp := map[string]float64{"q0": q0, "q50": q50, "q95": q95, "q99": q99, "q999": q999, "q100": q100, "avg": average}
 	return ops, mp
 }
 
@@ -206,6 +322,20 @@ func GetOverallLatencies(cmds []string, perQueryHistograms []*hdrhistogram.Histo
 	return perQueryQuantileMap, totalMap
 }
 
+// GetClientRunTimeStats returns the stats of a reporting period: the counters, the instant rate and the instant latencies of each query.
+// The caller needs to hold instantHistogramsResetMutex
+func GetClientRunTimeStats(queries []string, commands uint64, errors uint64, messageRate float64) map[string]interface{} {
+	clientLatencies, _ := GetOverallLatencies(queries, clientSide_PerQuery_InstantLatencies, clientSide_AllQueries_InstantLatencies)
+	graphInternalLatencies, _ := GetOverallLatencies(queries, serverSide_PerQuery_GraphInternalTime_InstantLatencies, serverSide_AllQueries_GraphInternalTime_InstantLatencies)
+	return map[string]interface{}{
+		"Commands":               commands,
+		"Errors":                 errors,
+		"MessageRate":            messageRate,
+		"ClientLatencies":        clientLatencies,
+		"GraphInternalLatencies": graphInternalLatencies,
+	}
+}
+
 func GenerateInternalExternalRatioLatencies(internal map[string]float64, external map[string]float64) (ratioMap map[string]float64, absoluteMap map[string]float64) {
 	ratioMap = map[string]float64{}
 	absoluteMap = map[string]float64{}
@@ -214,7 +344,7 @@ func GenerateInternalExternalRatioLatencies(internal map[string]float64, externa
 		externalQuantileValue := external[quantile]
 		absoluteDiff := externalQuantileValue - internalQuantileValue
 		relativeDiff := externalQuantileValue / internalQuantileValue
-		if !math.IsNaN(relativeDiff) {
+		if !math.IsNaN(relativeDiff) && !math.IsInf(relativeDiff, 0) {
 			ratioMap[quantile] = relativeDiff
 		}
 		if !math.IsNaN(absoluteDiff) {
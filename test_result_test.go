@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_processWarmupDatapoint(t *testing.T) {
+	createRequiredGlobalStructs(2, 1)
+	for _, dp := range []GraphQueryDatapoint{
+		{CmdPos: 0, ClientDurationMicros: 1000, GraphInternalDurationMicros: 500},
+		{CmdPos: 1, ClientDurationMicros: 3000, GraphInternalDurationMicros: 1500, Error: true},
+		{CmdPos: 1, ClientDurationMicros: 2000, GraphInternalDurationMicros: 1000},
+	} {
+		processWarmupDatapoint(dp)
+	}
+	if warmupCommands != 3 || warmupErrors != 1 || warmupErrorsPerQuery[0] != 0 || warmupErrorsPerQuery[1] != 1 {
+		t.Errorf("processWarmupDatapoint() commands = %d errors = %d per query = %v, want 3, 1 and [0 1]", warmupCommands, warmupErrors, warmupErrorsPerQuery)
+	}
+	if got := clientSide_PerQuery_WarmupLatencies[1].TotalCount(); got != 2 {
+		t.Errorf("processWarmupDatapoint() recorded %d client latencies of query 1, want 2", got)
+	}
+	if got := serverSide_AllQueries_GraphInternalTime_WarmupLatencies.Max(); got < 1500 || got > 1501 {
+		t.Errorf("processWarmupDatapoint() max internal latency = %d, want 1500", got)
+	}
+	if totalCommands != 0 || clientSide_AllQueries_OverallLatencies.TotalCount() != 0 {
+		t.Errorf("processWarmupDatapoint() accounted the warmup on the benchmark results")
+	}
+}
+
+func Test_processGraphDatapointsChannel_warmup(t *testing.T) {
+	createRequiredGlobalStructs(1, 1)
+	graphStatsChann := make(chan GraphQueryDatapoint, 2)
+	warmupStatsChann := make(chan GraphQueryDatapoint, 3)
+	for i := 0; i < 3; i++ {
+		warmupStatsChann <- GraphQueryDatapoint{ClientDurationMicros: 90000}
+	}
+	graphStatsChann <- GraphQueryDatapoint{ClientDurationMicros: 1000}
+	graphStatsChann <- GraphQueryDatapoint{ClientDurationMicros: 1000, Error: true}
+	close(graphStatsChann)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	wg.Add(1)
+	processGraphDatapointsChannel(graphStatsChann, warmupStatsChann, make(chan os.Signal), &wg, &mutex)
+	if warmupCommands != 3 || clientSide_AllQueries_WarmupLatencies.TotalCount() != 3 {
+		t.Errorf("processGraphDatapointsChannel() processed %d warmup datapoints, want 3", warmupCommands)
+	}
+	if totalCommands != 2 || totalErrors != 1 || clientSide_AllQueries_OverallLatencies.TotalCount() != 2 {
+		t.Errorf("processGraphDatapointsChannel() commands = %d errors = %d, want 2 and 1", totalCommands, totalErrors)
+	}
+	// the slower warmup latencies are not part of the benchmark results
+	if got := clientSide_AllQueries_OverallLatencies.Max(); got > 1001 {
+		t.Errorf("processGraphDatapointsChannel() overall max latency = %d, want 1000", got)
+	}
+}
+
+func Test_TestResult_FillWarmupInfo(t *testing.T) {
+	queries := []string{"CREATE (n)"}
+	createRequiredGlobalStructs(len(queries), 1)
+	processWarmupDatapoint(GraphQueryDatapoint{ClientDurationMicros: 2000, GraphInternalDurationMicros: 1000})
+	processWarmupDatapoint(GraphQueryDatapoint{ClientDurationMicros: 2000, GraphInternalDurationMicros: 1000, Error: true})
+	start := time.Unix(1600000000, 0)
+	r := NewTestResult("", 1, 0, 0, "")
+	r.FillWarmupInfo(start, start.Add(1500*time.Millisecond), queries)
+	if r.Warmup["StartTime"] != int64(1600000000000) || r.Warmup["DurationMillis"] != int64(1500) {
+		t.Errorf("FillWarmupInfo() window = %v, %v, want 1600000000000 and 1500", r.Warmup["StartTime"], r.Warmup["DurationMillis"])
+	}
+	if r.Warmup["IssuedCommands"] != uint64(2) || r.Warmup["Errors"] != uint64(1) {
+		t.Errorf("FillWarmupInfo() commands = %v errors = %v, want 2 and 1", r.Warmup["IssuedCommands"], r.Warmup["Errors"])
+	}
+	clientLatencies := r.Warmup["ClientLatencies"].(map[string]interface{})
+	if got := clientLatencies["CREATE (n)"].(map[string]float64)["q50"]; got != 2.0 {
+		t.Errorf("FillWarmupInfo() CREATE (n) client q50 = %v, want 2", got)
+	}
+	if got := r.Warmup["GraphInternalLatencies"].(map[string]interface{})["Total"].(map[string]float64)["q100"]; got != 1.0 {
+		t.Errorf("FillWarmupInfo() Total internal q100 = %v, want 1", got)
+	}
+}
+
+func Test_TestResult_FailedSLOs(t *testing.T) {
+	passed := []SLOResult{{SLO: "p99<5", Passed: true}}
+	failed := []SLOResult{{SLO: "p99<5", Passed: false}, {SLO: "error-rate<1", Passed: true}}
+	tests := []struct {
+		name   string
+		result *TestResult
+		want   int
+	}{
+		{"single-run", &TestResult{SLOs: failed}, 1},
+		{"phases", &TestResult{Phases: []PhaseResult{{Result: &TestResult{SLOs: failed}}, {Result: nil}, {Result: &TestResult{SLOs: passed}}}}, 1},
+		{"ramp", &TestResult{Ramp: &RampResult{Steps: []RampStepResult{{SLOs: passed}, {SLOs: failed}, {SLOs: failed}}}}, 2},
+		{"ramp-passed", &TestResult{Ramp: &RampResult{Steps: []RampStepResult{{SLOs: passed}}}}, 0},
+		{"sweep", &TestResult{Sweep: []SweepPointResult{{Result: &TestResult{SLOs: failed}}}}, 1},
+		{"repetitions", &TestResult{Repetitions: &RepetitionsResult{Runs: []*TestResult{{SLOs: failed}, {SLOs: passed}}}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.FailedSLOs(); got != tt.want {
+				t.Errorf("FailedSLOs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
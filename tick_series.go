@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tickSample is a single sample of a series. The labels identify the series ( metric, query, percentile and the run labels )
+type tickSample struct {
+	key    string
+	labels map[string]string
+	value  float64
+}
+
+// tickSeries builds the overall and per query series of each reporting period of a run
+type tickSeries struct {
+	exporters []benchmarkExporter
+	// series keys start with the run name. The phase and the clients are only added when the run has phases,
+	// so that each phase, sweep combination or ramp step gets its own series
+	keyPrefix       string
+	queries         []string
+	queryIsReadOnly []bool
+	// labels shared by all the series of the run ( run name, phase, clients, git sha and RedisGraph version )
+	commonLabels map[string]string
+	// counters at the previous reporting period, to compute the instant rates
+	prevCommands      uint64
+	prevErrors        uint64
+	prevQueryCommands []uint64
+	prevQueryErrors   []uint64
+}
+
+func newTickSeries(exporters []benchmarkExporter, runName string, gitSHA string, redisgraphVersion int64, w benchmarkWorkload) *tickSeries {
+	commonLabels := map[string]string{
+		"run_name":           runName,
+		"clients":            strconv.FormatUint(w.clients, 10),
+		"redisgraph_version": strconv.FormatInt(redisgraphVersion, 10),
+	}
+	// label values can not be empty
+	if gitSHA != "" {
+		commonLabels["git_sha"] = gitSHA
+	}
+	if w.name != "" {
+		commonLabels["phase"] = w.name
+	}
+	return &tickSeries{
+		exporters:         exporters,
+		keyPrefix:         getSeriesKeyPrefix(runName, w),
+		queries:           w.queries,
+		queryIsReadOnly:   w.queryIsReadOnly,
+		commonLabels:      commonLabels,
+		prevQueryCommands: make([]uint64, len(w.queries)),
+		prevQueryErrors:   make([]uint64, len(w.queries)),
+	}
+}
+
+// getSeriesKeyPrefix returns the run name for a single run, keeping the historical series keys,
+// or <run name>:<phase>:clients:<clients> with the phase spaces replaced by underscores
+func getSeriesKeyPrefix(runName string, w benchmarkWorkload) string {
+	if w.name == "" {
+		return runName
+	}
+	return fmt.Sprintf("%s:%s:clients:%d", runName, strings.ReplaceAll(w.name, " ", "_"), w.clients)
+}
+
+func (s *tickSeries) getLabels(metric string, query string, readOnly string, percentile string) map[string]string {
+	labels := map[string]string{"metric": metric, "query": query}
+	if readOnly != "" {
+		labels["query_ro"] = readOnly
+	}
+	if percentile != "" {
+		labels["percentile"] = percentile
+	}
+	for k, v := range s.commonLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// getIntervalErrorRate returns the percentage of errors in between two reporting periods
+func getIntervalErrorRate(commands, prevCommands, errors, prevErrors uint64) float64 {
+	if commands <= prevCommands {
+		return 0.0
+	}
+	return float64(errors-prevErrors) / float64(commands-prevCommands) * 100.0
+}
+
+// getSamples returns the samples of the reporting period. The caller needs to hold instantHistogramsResetMutex
+func (s *tickSeries) getSamples(took time.Duration, commands uint64, errors uint64) (samples []tickSample) {
+	samples = []tickSample{}
+	for _, percentile := range reportedPercentiles {
+		label := getPercentileLabel(percentile)
+		samples = append(samples,
+			tickSample{fmt.Sprintf("%s:overallIncludingRTT:p%.3f", s.keyPrefix, percentile), s.getLabels("overallIncludingRTT", "Total", "", label), float64(clientSide_AllQueries_OverallLatencies.ValueAtQuantile(percentile)) / 1000.0},
+			tickSample{fmt.Sprintf("%s:overallRunTimeGraph:p%.3f", s.keyPrefix, percentile), s.getLabels("overallRunTimeGraph", "Total", "", label), float64(serverSide_AllQueries_GraphInternalTime_OverallLatencies.ValueAtQuantile(percentile)) / 1000.0},
+			tickSample{fmt.Sprintf("%s:instantIncludingRTT:p%.3f", s.keyPrefix, percentile), s.getLabels("instantIncludingRTT", "Total", "", label), float64(clientSide_AllQueries_InstantLatencies.ValueAtQuantile(percentile)) / 1000.0},
+			tickSample{fmt.Sprintf("%s:instantRunTimeGraph:p%.3f", s.keyPrefix, percentile), s.getLabels("instantRunTimeGraph", "Total", "", label), float64(serverSide_AllQueries_GraphInternalTime_InstantLatencies.ValueAtQuantile(percentile)) / 1000.0},
+		)
+	}
+	samples = append(samples,
+		tickSample{fmt.Sprintf("%s:messageRate", s.keyPrefix), s.getLabels("messageRate", "Total", "", ""), calculateRateMetrics(int64(commands), int64(s.prevCommands), took)},
+		tickSample{fmt.Sprintf("%s:errorRate", s.keyPrefix), s.getLabels("errorRate", "Total", "", ""), getIntervalErrorRate(commands, s.prevCommands, errors, s.prevErrors)},
+	)
+	s.prevCommands = commands
+	s.prevErrors = errors
+	// the per query globals are recreated on each run
+	if len(clientSide_PerQuery_OverallLatencies) != len(s.queries) || len(errorsPerQuery) != len(s.queries) {
+		return
+	}
+	for i, query := range s.queries {
+		readOnly := strconv.FormatBool(s.queryIsReadOnly[i])
+		for _, percentile := range reportedPercentiles {
+			label := getPercentileLabel(percentile)
+			samples = append(samples,
+				tickSample{fmt.Sprintf("%s:query:%d:overallIncludingRTT:p%.3f", s.keyPrefix, i, percentile), s.getLabels("overallIncludingRTT", query, readOnly, label), float64(clientSide_PerQuery_OverallLatencies[i].ValueAtQuantile(percentile)) / 1000.0},
+				tickSample{fmt.Sprintf("%s:query:%d:overallRunTimeGraph:p%.3f", s.keyPrefix, i, percentile), s.getLabels("overallRunTimeGraph", query, readOnly, label), float64(serverSide_PerQuery_GraphInternalTime_OverallLatencies[i].ValueAtQuantile(percentile)) / 1000.0},
+			)
+		}
+		queryCommands := uint64(clientSide_PerQuery_OverallLatencies[i].TotalCount())
+		queryErrors := errorsPerQuery[i]
+		samples = append(samples,
+			tickSample{fmt.Sprintf("%s:query:%d:messageRate", s.keyPrefix, i), s.getLabels("messageRate", query, readOnly, ""), calculateRateMetrics(int64(queryCommands), int64(s.prevQueryCommands[i]), took)},
+			tickSample{fmt.Sprintf("%s:query:%d:errorRate", s.keyPrefix, i), s.getLabels("errorRate", query, readOnly, ""), getIntervalErrorRate(queryCommands, s.prevQueryCommands[i], queryErrors, s.prevQueryErrors[i])},
+		)
+		s.prevQueryCommands[i] = queryCommands
+		s.prevQueryErrors[i] = queryErrors
+	}
+	return
+}
+
+// getResultSamples returns the final results of the run as samples: the rate, latencies and counters of each query and across all queries
+func (s *tickSeries) getResultSamples(result *TestResult) (samples []tickSample) {
+	samples = []tickSample{}
+	// copied, so that appending "Total" never modifies the workload queries
+	queries := append(append([]string{}, s.queries...), "Total")
+	for i, query := range queries {
+		prefix := fmt.Sprintf("%s:result:query:%d", s.keyPrefix, i)
+		readOnly := ""
+		if query == "Total" {
+			prefix = fmt.Sprintf("%s:result", s.keyPrefix)
+		} else {
+			readOnly = strconv.FormatBool(s.queryIsReadOnly[i])
+		}
+		if rate, ok := result.OverallQueryRates[query].(float64); ok {
+			samples = append(samples, tickSample{fmt.Sprintf("%s:messageRate", prefix), s.getLabels("resultMessageRate", query, readOnly, ""), rate})
+		}
+		clientLatencies := toFloat64Values(result.OverallClientLatencies[query])
+		graphInternalLatencies := toFloat64Values(result.OverallGraphInternalLatencies[query])
+		for _, key := range getLatencyMapKeys() {
+			if v, found := clientLatencies[key]; found {
+				samples = append(samples, tickSample{fmt.Sprintf("%s:overallIncludingRTT:%s", prefix, key), s.getLabels("resultIncludingRTT", query, readOnly, key), v})
+			}
+			if v, found := graphInternalLatencies[key]; found {
+				samples = append(samples, tickSample{fmt.Sprintf("%s:overallRunTimeGraph:%s", prefix, key), s.getLabels("resultRunTimeGraph", query, readOnly, key), v})
+			}
+		}
+		totals := toFloat64Values(result.Totals[query])
+		totalKeys := make([]string, 0, len(totals))
+		for key := range totals {
+			totalKeys = append(totalKeys, key)
+		}
+		sort.Strings(totalKeys)
+		for _, key := range totalKeys {
+			samples = append(samples, tickSample{fmt.Sprintf("%s:%s", prefix, key), s.getLabels("result"+key, query, readOnly, ""), totals[key]})
+		}
+	}
+	return
+}
+
+// toFloat64Values converts the in-memory ( or loaded from json ) latency and totals maps
+func toFloat64Values(v interface{}) map[string]float64 {
+	switch m := v.(type) {
+	case map[string]float64:
+		return m
+	case map[string]uint64:
+		values := map[string]float64{}
+		for key, value := range m {
+			values[key] = float64(value)
+		}
+		return values
+	}
+	return toFloat64Map(v)
+}
+
+// add hands the samples of the reporting period to each of the exporters
+func (s *tickSeries) add(samples []tickSample, now time.Time) {
+	for _, exporter := range s.exporters {
+		exporter.exportTick(samples, now)
+	}
+}
+
+// addResult hands the final results of the run to each of the exporters
+func (s *tickSeries) addResult(result *TestResult, now time.Time) {
+	samples := s.getResultSamples(result)
+	for _, exporter := range s.exporters {
+		exporter.exportResult(result, samples, now)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_tickSeries_getSamples(t *testing.T) {
+	defer func(previous []float64) { reportedPercentiles = previous }(reportedPercentiles)
+	reportedPercentiles = []float64{50}
+	w := benchmarkWorkload{queries: []string{"CREATE (n)", "MATCH (n) RETURN n"}, queryIsReadOnly: []bool{false, true}, clients: 8}
+	createRequiredGlobalStructs(len(w.queries), 1)
+	series := newTickSeries(nil, "perf-run", "", 20811, w)
+	for _, v := range []int64{1000, 2000, 3000, 4000} {
+		clientSide_PerQuery_OverallLatencies[1].RecordValue(v)
+	}
+	errorsPerQuery[1] = 1
+	samples := series.getSamples(time.Second, 4, 1)
+	got := map[string]tickSample{}
+	for _, dp := range samples {
+		got[dp.key] = dp
+	}
+	tests := []struct {
+		key        string
+		want       float64
+		wantLabels map[string]string
+	}{
+		{"perf-run:messageRate", 4, map[string]string{"metric": "messageRate", "query": "Total", "run_name": "perf-run", "clients": "8", "redisgraph_version": "20811"}},
+		{"perf-run:errorRate", 25, map[string]string{"metric": "errorRate", "query": "Total"}},
+		{"perf-run:query:1:overallIncludingRTT:p50.000", 2, map[string]string{"metric": "overallIncludingRTT", "query": "MATCH (n) RETURN n", "query_ro": "true", "percentile": "p50"}},
+		{"perf-run:query:1:messageRate", 4, map[string]string{"query_ro": "true"}},
+		{"perf-run:query:1:errorRate", 25, map[string]string{"metric": "errorRate"}},
+		{"perf-run:query:0:errorRate", 0, map[string]string{"query": "CREATE (n)", "query_ro": "false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			dp, found := got[tt.key]
+			if !found {
+				t.Fatalf("getSamples() is missing series %s", tt.key)
+			}
+			if dp.value != tt.want {
+				t.Errorf("getSamples() %s = %v, want %v", tt.key, dp.value, tt.want)
+			}
+			for k, v := range tt.wantLabels {
+				if dp.labels[k] != v {
+					t.Errorf("getSamples() %s label %s = %v, want %v", tt.key, k, dp.labels[k], v)
+				}
+			}
+			if _, found := dp.labels["git_sha"]; found {
+				t.Errorf("getSamples() %s has an empty git_sha label", tt.key)
+			}
+		})
+	}
+	// the rates are relative to the previous reporting period
+	samples = series.getSamples(time.Second, 4, 1)
+	for _, dp := range samples {
+		if dp.key == "perf-run:query:1:messageRate" && dp.value != 0 {
+			t.Errorf("getSamples() second period %s = %v, want 0", dp.key, dp.value)
+		}
+	}
+}
+
+func Test_getSeriesKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		w    benchmarkWorkload
+		want string
+	}{
+		{"single-run", benchmarkWorkload{clients: 50}, "perf-run"},
+		{"ramp-step", benchmarkWorkload{name: "ramp step 2", clients: 50}, "perf-run:ramp_step_2:clients:50"},
+		{"scenario-phase", benchmarkWorkload{name: "reads", clients: 10}, "perf-run:reads:clients:10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getSeriesKeyPrefix("perf-run", tt.w); got != tt.want {
+				t.Errorf("getSeriesKeyPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_tickSeries_getSamples_whileProcessing builds the samples, as updateCLI does, while the datapoints are being processed. Run with -race
+func Test_tickSeries_getSamples_whileProcessing(t *testing.T) {
+	w := benchmarkWorkload{queries: []string{"CREATE (n)", "MATCH (n) RETURN n"}, queryIsReadOnly: []bool{false, true}, clients: 8}
+	instantHistogramsResetMutex.Lock()
+	createRequiredGlobalStructs(len(w.queries), 1)
+	instantHistogramsResetMutex.Unlock()
+	series := newTickSeries(nil, "perf-run", "", 0, w)
+	graphStatsChann := make(chan GraphQueryDatapoint, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go processGraphDatapointsChannel(graphStatsChann, make(chan GraphQueryDatapoint), make(chan os.Signal), &wg, &instantHistogramsResetMutex)
+	processed := make(chan struct{})
+	ticks := sync.WaitGroup{}
+	ticks.Add(1)
+	go func() {
+		defer ticks.Done()
+		for {
+			select {
+			case <-processed:
+				return
+			default:
+				instantHistogramsResetMutex.Lock()
+				series.getSamples(time.Millisecond, 0, 0)
+				instantHistogramsResetMutex.Unlock()
+			}
+		}
+	}()
+	for i := 0; i < 500; i++ {
+		graphStatsChann <- GraphQueryDatapoint{CmdPos: i % 2, ClientDurationMicros: 1000, Error: i%10 == 0}
+	}
+	close(graphStatsChann)
+	wg.Wait()
+	close(processed)
+	ticks.Wait()
+	if errorsPerQuery[0] != 50 {
+		t.Errorf("processGraphDatapointsChannel() CREATE (n) errors = %d, want 50", errorsPerQuery[0])
+	}
+}
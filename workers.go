@@ -11,20 +11,72 @@ import (
 	"time"
 )
 
-func ingestionRoutine(rg *redisgraph.Graph, continueOnError bool, cmdS []string, commandIsRO []bool, commandsCDF []float32, randomIntPadding, randomIntMax int64, number_samples uint64, loop bool, debug_level int, wg *sync.WaitGroup, useLimiter bool, rateLimiter *rate.Limiter, statsChannel chan GraphQueryDatapoint, replacementEnabled bool, replacementArr []map[string]string, commandStartPos uint64) {
+func ingestionRoutine(rg *redisgraph.Graph, roRg *redisgraph.Graph, rgEndpointPos, roRgEndpointPos int, continueOnError bool, cmdS []string, commandIsRO []bool, commandsCDF []float32, randomIntPadding, randomIntMax int64, number_samples uint64, loop bool, deadline time.Time, stop <-chan struct{}, pipeline uint64, debug_level int, wg *sync.WaitGroup, useLimiter bool, rateLimiter *rate.Limiter, statsChannel chan GraphQueryDatapoint, replacementEnabled bool, replacementArr []map[string]string, commandStartPos uint64) {
 	defer wg.Done()
 	var replacementTerms map[string]string
-	for i := 0; uint64(i) < number_samples || loop; i++ {
-		cmdPos := sample(commandsCDF)
-		termReplacementPos := commandStartPos + uint64(i)
-		if replacementEnabled {
-			replacementTerms = replacementArr[termReplacementPos]
+	for i := 0; (uint64(i) < number_samples || loop) && (deadline.IsZero() || time.Now().Before(deadline)) && !isStopped(stop); {
+		// the last pipeline might be shorter given the commands might not be divisible by the pipeline size
+		batchSize := pipeline
+		if !loop && number_samples-uint64(i) < batchSize {
+			batchSize = number_samples - uint64(i)
 		}
-		sendCmdLogic(rg, cmdS[cmdPos], commandIsRO[cmdPos], randomIntPadding, randomIntMax, cmdPos, continueOnError, debug_level, useLimiter, rateLimiter, statsChannel, replacementEnabled, replacementTerms)
+		if batchSize <= 1 {
+			cmdPos := sample(commandsCDF)
+			termReplacementPos := commandStartPos + uint64(i)
+			if replacementEnabled {
+				replacementTerms = replacementArr[termReplacementPos%uint64(len(replacementArr))]
+			}
+			// read-only queries are routed to the read-only graph connection ( which might be a replica )
+			cmdRg, endpointPos := rg, rgEndpointPos
+			if commandIsRO[cmdPos] {
+				cmdRg, endpointPos = roRg, roRgEndpointPos
+			}
+			sendCmdLogic(cmdRg, cmdS[cmdPos], commandIsRO[cmdPos], randomIntPadding, randomIntMax, cmdPos, endpointPos, continueOnError, debug_level, useLimiter, rateLimiter, statsChannel, replacementEnabled, replacementTerms)
+			i++
+			continue
+		}
+		// split the pipeline in between the read/write and read-only connections
+		rwCmds := make([]int, 0, batchSize)
+		rwQueries := make([]string, 0, batchSize)
+		roCmds := make([]int, 0, batchSize)
+		roQueries := make([]string, 0, batchSize)
+		for j := uint64(0); j < batchSize; j++ {
+			cmdPos := sample(commandsCDF)
+			termReplacementPos := commandStartPos + uint64(i) + j
+			if replacementEnabled {
+				replacementTerms = replacementArr[termReplacementPos%uint64(len(replacementArr))]
+			}
+			processedQuery := processQuery(cmdS[cmdPos], randomIntPadding, randomIntMax, replacementEnabled, replacementTerms)
+			// without replicas both graphs share the same connection, and the whole pipeline is sent at once
+			if commandIsRO[cmdPos] && roRg.Conn != rg.Conn {
+				roCmds = append(roCmds, cmdPos)
+				roQueries = append(roQueries, processedQuery)
+			} else {
+				rwCmds = append(rwCmds, cmdPos)
+				rwQueries = append(rwQueries, processedQuery)
+			}
+		}
+		if useLimiter {
+			r := rateLimiter.ReserveN(time.Now(), int(batchSize))
+			time.Sleep(r.Delay())
+		}
+		sendPipelinedCmdsLogic(rg, rwQueries, rwCmds, commandIsRO, rgEndpointPos, continueOnError, debug_level, statsChannel)
+		sendPipelinedCmdsLogic(roRg, roQueries, roCmds, commandIsRO, roRgEndpointPos, continueOnError, debug_level, statsChannel)
+		i += int(batchSize)
+	}
+}
+
+// isStopped is true once stop is closed. A nil stop is never closed
+func isStopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
 	}
 }
 
-func sendCmdLogic(rg *redisgraph.Graph, query string, readOnly bool, randomIntPadding, randomIntMax int64, cmdPos int, continueOnError bool, debug_level int, useRateLimiter bool, rateLimiter *rate.Limiter, statsChannel chan GraphQueryDatapoint, replacementEnabled bool, replacementTerms map[string]string) {
+func sendCmdLogic(rg *redisgraph.Graph, query string, readOnly bool, randomIntPadding, randomIntMax int64, cmdPos int, endpointPos int, continueOnError bool, debug_level int, useRateLimiter bool, rateLimiter *rate.Limiter, statsChannel chan GraphQueryDatapoint, replacementEnabled bool, replacementTerms map[string]string) {
 	if useRateLimiter {
 		r := rateLimiter.ReserveN(time.Now(), int(1))
 		time.Sleep(r.Delay())
@@ -42,8 +94,53 @@ func sendCmdLogic(rg *redisgraph.Graph, query string, readOnly bool, randomIntPa
 	endT := time.Now()
 
 	duration := endT.Sub(startT)
+	statsChannel <- newGraphQueryDatapoint(queryResult, err, query, cmdPos, endpointPos, duration, continueOnError, debug_level)
+}
+
+// sendPipelinedCmdsLogic writes all queries on the connection before reading any reply.
+// The latency of each query is measured from the moment the pipeline is written up until its reply is read.
+func sendPipelinedCmdsLogic(rg *redisgraph.Graph, queries []string, cmdsPos []int, commandIsRO []bool, endpointPos int, continueOnError bool, debug_level int, statsChannel chan GraphQueryDatapoint) {
+	if len(queries) == 0 {
+		return
+	}
+	replies := make([]interface{}, len(queries))
+	errs := make([]error, len(queries))
+	durations := make([]time.Duration, len(queries))
+
+	startT := time.Now()
+	for j, query := range queries {
+		graphCmd := "GRAPH.QUERY"
+		if commandIsRO[cmdsPos[j]] {
+			graphCmd = "GRAPH.RO_QUERY"
+		}
+		errs[j] = rg.Conn.Send(graphCmd, rg.Id, query, "--compact")
+	}
+	flushErr := rg.Conn.Flush()
+	for j := range queries {
+		if errs[j] == nil {
+			errs[j] = flushErr
+		}
+		if errs[j] == nil {
+			replies[j], errs[j] = rg.Conn.Receive()
+		}
+		durations[j] = time.Since(startT)
+	}
+	// the replies are only parsed once the whole pipeline was read, given parsing a compact reply
+	// might require issuing additional commands on the same connection
+	for j, query := range queries {
+		var queryResult *redisgraph.QueryResult
+		err := errs[j]
+		if err == nil {
+			queryResult, err = redisgraph.QueryResultNew(rg, replies[j])
+		}
+		statsChannel <- newGraphQueryDatapoint(queryResult, err, query, cmdsPos[j], endpointPos, durations[j], continueOnError, debug_level)
+	}
+}
+
+func newGraphQueryDatapoint(queryResult *redisgraph.QueryResult, err error, query string, cmdPos int, endpointPos int, duration time.Duration, continueOnError bool, debug_level int) GraphQueryDatapoint {
 	datapoint := GraphQueryDatapoint{
 		CmdPos:                      cmdPos,
+		EndpointPos:                 endpointPos,
 		ClientDurationMicros:        duration.Microseconds(),
 		GraphInternalDurationMicros: 0,
 		Error:                       false,
@@ -80,7 +177,7 @@ func sendCmdLogic(rg *redisgraph.Graph, query string, readOnly bool, randomIntPa
 		datapoint.RelationshipsCreated = uint64(queryResult.RelationshipsCreated())
 		datapoint.RelationshipsDeleted = uint64(queryResult.RelationshipsDeleted())
 	}
-	statsChannel <- datapoint
+	return datapoint
 }
 
 func processQuery(query string, randomIntPadding int64, randomIntMax int64, replacementEnabled bool, replacementTerms map[string]string) string {
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"github.com/RedisGraph/redisgraph-go"
 	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 )
 
 func Test_processQuery(t *testing.T) {
@@ -38,3 +42,170 @@ func Test_processQuery(t *testing.T) {
 		})
 	}
 }
+
+// fakeGraphConn replies to every query with a compact reply holding only the query statistics,
+// recording the commands and the size of each flushed pipeline
+type fakeGraphConn struct {
+	commands   []string
+	batches    []int
+	unflushed  int
+	replyDelay time.Duration
+}
+
+func fakeGraphReply() interface{} {
+	return []interface{}{[]interface{}{[]byte("Nodes created: 1"), []byte("Query internal execution time: 0.250000 milliseconds")}}
+}
+
+func (c *fakeGraphConn) Close() error { return nil }
+func (c *fakeGraphConn) Err() error   { return nil }
+
+func (c *fakeGraphConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.commands = append(c.commands, commandName)
+	return fakeGraphReply(), nil
+}
+
+func (c *fakeGraphConn) Send(commandName string, args ...interface{}) error {
+	c.commands = append(c.commands, commandName)
+	c.unflushed++
+	return nil
+}
+
+func (c *fakeGraphConn) Flush() error {
+	c.batches = append(c.batches, c.unflushed)
+	c.unflushed = 0
+	return nil
+}
+
+func (c *fakeGraphConn) Receive() (interface{}, error) {
+	time.Sleep(c.replyDelay)
+	return fakeGraphReply(), nil
+}
+
+func Test_ingestionRoutine(t *testing.T) {
+	rand.Seed(12345)
+	tests := []struct {
+		name        string
+		commandIsRO []bool
+		cdf         []float32
+		samples     uint64
+		pipeline    uint64
+		// read-only queries are routed to a replica connection
+		replica       bool
+		wantRwBatches []int
+	}{
+		{"no-pipeline", []bool{false}, []float32{1}, 5, 1, false, nil},
+		{"pipeline-remainder", []bool{false}, []float32{1}, 10, 4, false, []int{4, 4, 2}},
+		{"pipeline-larger-than-requests", []bool{false}, []float32{1}, 3, 5, false, []int{3}},
+		{"read-only-same-connection", []bool{false, true}, []float32{0.5, 1}, 8, 4, false, []int{4, 4}},
+		{"read-only-same-connection-mostly-ro", []bool{false, true}, []float32{0.1, 1}, 12, 4, false, []int{4, 4, 4}},
+		{"pipeline-remainder-of-one", []bool{false}, []float32{1}, 9, 4, false, []int{4, 4}},
+		{"read-only-on-replica", []bool{false, true}, []float32{0.5, 1}, 22, 4, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rwConn, roConn := &fakeGraphConn{}, &fakeGraphConn{}
+			rg := redisgraph.GraphNew("graph", rwConn)
+			// as on connect(), without replicas the read-only graph is a copy sharing the same connection
+			ro := rg
+			roRg, roEndpointPos := &ro, 0
+			if tt.replica {
+				ro = redisgraph.GraphNew("graph", roConn)
+				roEndpointPos = 1
+			}
+			statsChannel := make(chan GraphQueryDatapoint, tt.samples)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			ingestionRoutine(&rg, roRg, 0, roEndpointPos, true, []string{"CREATE (n)", "MATCH (n) RETURN n"}, tt.commandIsRO, tt.cdf, 0, 1, tt.samples, false, time.Time{}, nil, tt.pipeline, 0, &wg, false, nil, statsChannel, false, nil, 0)
+			close(statsChannel)
+			datapoints := map[int]int{}
+			for datapoint := range statsChannel {
+				wantEndpointPos := 0
+				if tt.commandIsRO[datapoint.CmdPos] && tt.replica {
+					wantEndpointPos = 1
+				}
+				if datapoint.EndpointPos != wantEndpointPos {
+					t.Errorf("query %d was attributed to endpoint %d, want %d", datapoint.CmdPos, datapoint.EndpointPos, wantEndpointPos)
+				}
+				datapoints[datapoint.EndpointPos]++
+			}
+			if got := len(rwConn.commands) + len(roConn.commands); got != int(tt.samples) {
+				t.Errorf("ingestionRoutine() sent %d commands, want %d", got, tt.samples)
+			}
+			if datapoints[0] != len(rwConn.commands) || datapoints[1] != len(roConn.commands) {
+				t.Errorf("ingestionRoutine() recorded %v datapoints per endpoint, want %d and %d", datapoints, len(rwConn.commands), len(roConn.commands))
+			}
+			// a single flush per pipeline
+			if tt.wantRwBatches != nil && !reflect.DeepEqual(rwConn.batches, tt.wantRwBatches) {
+				t.Errorf("ingestionRoutine() pipelines = %v, want %v", rwConn.batches, tt.wantRwBatches)
+			}
+			if tt.replica {
+				for _, commandName := range rwConn.commands {
+					if commandName != "GRAPH.QUERY" {
+						t.Errorf("ingestionRoutine() sent %s to the read/write connection", commandName)
+					}
+				}
+				for _, commandName := range roConn.commands {
+					if commandName != "GRAPH.RO_QUERY" {
+						t.Errorf("ingestionRoutine() sent %s to the replica connection", commandName)
+					}
+				}
+				// each pipeline is split in between both connections
+				if got := sumInts(rwConn.batches) + sumInts(roConn.batches); got != int(tt.samples) {
+					t.Errorf("ingestionRoutine() pipelined %d commands, want %d", got, tt.samples)
+				}
+				if len(rwConn.batches) > 6 || len(roConn.batches) > 6 {
+					t.Errorf("ingestionRoutine() flushed %d and %d pipelines, want at most 6 on each connection", len(rwConn.batches), len(roConn.batches))
+				}
+			}
+		})
+	}
+}
+
+func sumInts(values []int) (total int) {
+	for _, v := range values {
+		total += v
+	}
+	return
+}
+
+func Test_sendPipelinedCmdsLogic(t *testing.T) {
+	conn := &fakeGraphConn{replyDelay: 2 * time.Millisecond}
+	rg := redisgraph.GraphNew("graph", conn)
+	statsChannel := make(chan GraphQueryDatapoint, 3)
+	sendPipelinedCmdsLogic(&rg, []string{"CREATE (n)", "MATCH (n) RETURN n", "CREATE (n)"}, []int{0, 1, 0}, []bool{false, true}, 2, true, 0, statsChannel)
+	close(statsChannel)
+	if want := []string{"GRAPH.QUERY", "GRAPH.RO_QUERY", "GRAPH.QUERY"}; !reflect.DeepEqual(conn.commands, want) || !reflect.DeepEqual(conn.batches, []int{3}) {
+		t.Fatalf("sendPipelinedCmdsLogic() sent %v in pipelines %v, want %v in a single pipeline", conn.commands, conn.batches, want)
+	}
+	// each query accounts for the time up until its own reply was read
+	prevDuration := int64(0)
+	j := 0
+	for datapoint := range statsChannel {
+		if datapoint.CmdPos != []int{0, 1, 0}[j] || datapoint.EndpointPos != 2 || datapoint.Error {
+			t.Errorf("datapoint %d = %+v, want query %d on endpoint 2", j, datapoint, []int{0, 1, 0}[j])
+		}
+		if minDuration := int64(2000 * (j + 1)); datapoint.ClientDurationMicros < minDuration || datapoint.ClientDurationMicros <= prevDuration {
+			t.Errorf("datapoint %d latency = %dus, want at least %dus and more than the previous %dus", j, datapoint.ClientDurationMicros, minDuration, prevDuration)
+		}
+		prevDuration = datapoint.ClientDurationMicros
+		j++
+	}
+	if j != 3 {
+		t.Errorf("sendPipelinedCmdsLogic() recorded %d datapoints, want 3", j)
+	}
+}
+
+func Test_ingestionRoutine_stopped(t *testing.T) {
+	conn := &fakeGraphConn{}
+	rg := redisgraph.GraphNew("graph", conn)
+	stop := make(chan struct{})
+	close(stop)
+	statsChannel := make(chan GraphQueryDatapoint, 10)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	// a looping client stops issuing commands once stop is closed
+	ingestionRoutine(&rg, &rg, 0, 0, true, []string{"CREATE (n)"}, []bool{false}, []float32{1}, 0, 1, 0, true, time.Time{}, stop, 1, 0, &wg, false, nil, statsChannel, false, nil, 0)
+	if len(conn.commands) != 0 {
+		t.Errorf("ingestionRoutine() sent %d commands after being stopped, want 0", len(conn.commands))
+	}
+}